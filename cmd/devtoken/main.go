@@ -0,0 +1,63 @@
+// Command devtoken mints access tokens signed with the development key in dev/, which the run
+// targets of the Makefile trust. It must never be used outside of local development.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/opplieam/dist-mono/internal/auth"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	keyFile := flag.String("key", "dev/jwt-key.pem", "PEM private key signing the token")
+	kid := flag.String("kid", "dev", "kid header of the token, the key id in the JWKS")
+	issuer := flag.String("issuer", "dist-mono-dev", "iss claim")
+	audience := flag.String("audience", "dist-mono", "aud claim")
+	subject := flag.String("sub", "1", "sub claim, the user id")
+	roles := flag.String("roles", "", "Comma-separated roles, e.g. admin")
+	ttl := flag.Duration("ttl", time.Hour, "Lifetime of the token")
+	flag.Parse()
+
+	data, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return fmt.Errorf("read key: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+	if err != nil {
+		return fmt.Errorf("parse key: %w", err)
+	}
+
+	now := time.Now()
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    *issuer,
+			Subject:   *subject,
+			Audience:  jwt.ClaimStrings{*audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(*ttl)),
+		},
+	}
+	if *roles != "" {
+		claims.Roles = strings.Split(*roles, ",")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = *kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return fmt.Errorf("sign token: %w", err)
+	}
+	fmt.Println(signed)
+	return nil
+}
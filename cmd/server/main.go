@@ -2,61 +2,80 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
-	"time"
-
-	"github.com/jackc/pgx/v5"
-	db "github.com/opplieam/dist-mono/db/sqlc"
-	catApi "github.com/opplieam/dist-mono/internal/category/api"
-	catHandler "github.com/opplieam/dist-mono/internal/category/handler"
-	catStore "github.com/opplieam/dist-mono/internal/category/store"
-	userHandler "github.com/opplieam/dist-mono/internal/user/handler"
-	userStore "github.com/opplieam/dist-mono/internal/user/store"
+
+	"github.com/opplieam/dist-mono/internal/config"
+	"github.com/opplieam/dist-mono/internal/database"
+	"github.com/opplieam/dist-mono/internal/lifecycle"
+	appLogger "github.com/opplieam/dist-mono/internal/logger"
+	"github.com/opplieam/dist-mono/internal/telemetry"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
-const (
-	OtelEndpoint         = "localhost:4317"
-	MetricReportInterval = 5 * time.Second
-)
+// processName is the service.name of the telemetry shared by the services of a process running
+// several of them, such as the DB pool metrics.
+const processName = "dist-mono"
 
-func initMeter(target string) (*sdkmetric.MeterProvider, error) {
-	// Create resource
+func newResource(serviceName string) (*resource.Resource, error) {
 	res, err := resource.Merge(
 		resource.Default(),
 		resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(target),
+			semconv.ServiceNameKey.String(serviceName),
 		),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("unable to merge otel resource: %w", err)
 	}
+	return res, nil
+}
+
+// newOtelConn returns the connection to the collector shared by the exporters of every service.
+// It connects lazily.
+func newOtelConn(cfg config.OtelConfig) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(cfg.Endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create otel collector connection: %w", err)
+	}
+	return conn, nil
+}
+
+func initMeter(serviceName string, conn *grpc.ClientConn, cfg config.OtelConfig) (*sdkmetric.MeterProvider, error) {
+	// Create resource
+	res, err := newResource(serviceName)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create otel exporter
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
 	defer cancel()
 
 	exporter, err := otlpmetricgrpc.New(
 		ctx,
-		otlpmetricgrpc.WithEndpoint(OtelEndpoint),
+		otlpmetricgrpc.WithGRPCConn(conn),
 		otlpmetricgrpc.WithCompressor("gzip"),
-		otlpmetricgrpc.WithInsecure(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create otlpmetricgrpc exporter: %w", err)
 	}
 
-	periodicReader := sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(MetricReportInterval))
+	periodicReader := sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(cfg.MetricInterval))
 
 	// Create meter provider
 	provider := sdkmetric.NewMeterProvider(
@@ -64,75 +83,234 @@ func initMeter(target string) (*sdkmetric.MeterProvider, error) {
 		sdkmetric.WithReader(periodicReader),
 	)
 
-	otel.SetMeterProvider(provider)
 	return provider, nil
 }
 
-func main() {
-	target := flag.String("target", "", "Service to run (user or category)")
-	flag.Parse()
-
-	if *target != "user" && *target != "category" {
-		log.Fatalf("Invalid target: %s. Must be 'user' or 'category'", *target)
+func initTracer(serviceName string, conn *grpc.ClientConn, cfg config.OtelConfig) (*sdktrace.TracerProvider, error) {
+	// Create resource
+	res, err := newResource(serviceName)
+	if err != nil {
+		return nil, err
 	}
-	// Metric
-	provider, err := initMeter(*target)
+
+	// Create otel exporter
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	exporter, err := otlptracegrpc.New(
+		ctx,
+		otlptracegrpc.WithGRPCConn(conn),
+		otlptracegrpc.WithCompressor("gzip"),
+	)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("unable to create otlptracegrpc exporter: %w", err)
+	}
+
+	// Create tracer provider
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(exporter),
+	)
+	return provider, nil
+}
+
+// telemetryHooks returns the hooks of the meter and tracer providers of serviceName. Once started
+// they are set in tel, or as the global providers when tel is nil.
+func telemetryHooks(serviceName string, conn *grpc.ClientConn, cfg config.OtelConfig, tel *serviceTelemetry) []lifecycle.Hook {
+	var meterProvider *sdkmetric.MeterProvider
+	var tracerProvider *sdktrace.TracerProvider
+	return []lifecycle.Hook{
+		{
+			Name: serviceName + "-meter",
+			Start: func(context.Context) (err error) {
+				if meterProvider, err = initMeter(serviceName, conn, cfg); err != nil {
+					return err
+				}
+				if tel != nil {
+					tel.meterProvider = meterProvider
+				} else {
+					otel.SetMeterProvider(meterProvider)
+				}
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				return meterProvider.Shutdown(ctx)
+			},
+			Timeout: cfg.Timeout,
+		},
+		{
+			Name: serviceName + "-tracer",
+			Start: func(context.Context) (err error) {
+				if tracerProvider, err = initTracer(serviceName, conn, cfg); err != nil {
+					return err
+				}
+				if tel != nil {
+					tel.tracerProvider = tracerProvider
+				} else {
+					otel.SetTracerProvider(tracerProvider)
+				}
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				return tracerProvider.Shutdown(ctx)
+			},
+			Timeout: cfg.Timeout,
+		},
 	}
-	defer func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if errPro := provider.Shutdown(ctx); errPro != nil {
-			log.Fatal(err)
+}
+
+func main() {
+	if err := run(); err != nil {
+		slog.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
 		}
-	}()
+		return err
+	}
+	if cfg.PrintConfig {
+		return cfg.Print(os.Stdout)
+	}
 
-	// DB
-	conn, err := pgx.Connect(context.Background(), os.Getenv("DB_DSN"))
+	baseLogger, err := appLogger.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer conn.Close(context.Background())
+	logger := baseLogger.With("service", cfg.Target)
+	slog.SetDefault(logger)
 
-	query := db.New(conn)
+	poolCfg := database.PoolConfig{
+		DSN:               cfg.DB.DSN,
+		MaxConns:          int32(cfg.DB.MaxConns),
+		MinConns:          int32(cfg.DB.MinConns),
+		MaxConnLifetime:   cfg.DB.MaxConnLifetime,
+		HealthCheckPeriod: cfg.DB.HealthCheckPeriod,
+		AcquireTimeout:    cfg.DB.AcquireTimeout,
+	}
 
-	switch *target {
-	case "category":
-		fmt.Println("Starting category service")
-		store := catStore.NewStore(query)
-		cHandler := catHandler.NewCategoryHandler(store)
-		sig, err := cHandler.Start()
+	if cfg.Target == "migrate" {
+		pool, err := database.NewPool(context.Background(), poolCfg)
 		if err != nil {
-			log.Fatal(err)
-		}
-		<-sig
-		fmt.Println("Shutting down category service")
-		err = cHandler.Shutdown()
-		if err != nil {
-			log.Fatal(err)
-		}
-		fmt.Println("Gratefully shutting down category service")
-	case "user":
-		categoryClient, aErr := catApi.NewClient("http://localhost:4000/v1")
-		if aErr != nil {
-			log.Fatal(aErr)
+			return err
 		}
+		defer pool.Close()
+		return runMigrate(context.Background(), pool, cfg.Args)
+	}
 
-		fmt.Println("Starting user service")
-		store := userStore.NewStore(query, categoryClient)
-		uHandler := userHandler.NewUserHandler(store)
-		sig, err := uHandler.Start()
-		if err != nil {
-			log.Fatal(err)
+	// Hooks stop in reverse order: the server first, then the DB pool, and the telemetry
+	// providers last so they export what was recorded during shutdown.
+	lc := lifecycle.New(logger, lifecycle.Config{
+		DrainPeriod: cfg.DrainPeriod,
+		StopTimeout: cfg.ShutdownTimeout,
+	})
+
+	// Telemetry: the global providers are named after the service when it runs alone. With
+	// several services they are named after the process and only record what the services share,
+	// and each service gets its own. They all export through one collector connection, closed
+	// after every provider is shut down.
+	otel.SetTextMapPropagator(telemetry.Propagator)
+	otelConn, err := newOtelConn(cfg.Otel)
+	if err != nil {
+		return err
+	}
+	lc.Append(lifecycle.Hook{
+		Name: "otel-conn",
+		Stop: func(context.Context) error {
+			return otelConn.Close()
+		},
+	})
+	services := cfg.Services()
+	globalName := processName
+	if len(services) == 1 {
+		globalName = services[0]
+	}
+	for _, h := range telemetryHooks(globalName, otelConn, cfg.Otel, nil) {
+		lc.Append(h)
+	}
+
+	// DB: the services share the pool, its metrics go to the global provider.
+	var pool *database.Pool
+	lc.Append(lifecycle.Hook{
+		Name: "db",
+		Start: func(ctx context.Context) (err error) {
+			pool, err = database.NewPool(ctx, poolCfg)
+			if err != nil {
+				return err
+			}
+			if err = pool.RegisterMetrics(); err != nil {
+				pool.Close()
+				return err
+			}
+			return nil
+		},
+		Stop: func(context.Context) error {
+			pool.Close()
+			return nil
+		},
+	})
+
+	verifier, err := newVerifier(cfg.Auth)
+	if err != nil {
+		return err
+	}
+
+	var categoryHandler http.Handler
+	// invalidateUserCategories drops the categories the user service cached, it is only set
+	// when the category service runs in-process. Out of process the cached copies stay stale
+	// for up to the category cache TTL.
+	var invalidateUserCategories func(userID int)
+	for _, name := range services {
+		svcLogger := baseLogger.With("service", name)
+		// tel stays empty when the service runs alone, it then uses the global providers.
+		tel := &serviceTelemetry{}
+		if len(services) > 1 {
+			for _, h := range telemetryHooks(name, otelConn, cfg.Otel, tel) {
+				lc.Append(h)
+			}
 		}
-		<-sig
-		fmt.Println("Shutting down user service")
-		err = uHandler.Shutdown()
-		if err != nil {
-			log.Fatal(err)
+		switch name {
+		case "category":
+			lc.Append(serviceHook(name, svcLogger, func() (service, error) {
+				h, err := newCategoryService(cfg, svcLogger, pool, verifier, tel, func(userID int) {
+					if invalidateUserCategories != nil {
+						invalidateUserCategories(userID)
+					}
+				})
+				if err != nil {
+					return nil, err
+				}
+				if cfg.User.CategoryInProcess {
+					if categoryHandler, err = h.Handler(); err != nil {
+						return nil, err
+					}
+				}
+				return h, nil
+			}))
+		case "user":
+			// purgeIdempotencyKeys is the purge of the store the service is built with.
+			var purgeIdempotencyKeys func(ctx context.Context) (int64, error)
+			lc.Append(serviceHook(name, svcLogger, func() (service, error) {
+				h, store, err := newUserService(cfg, svcLogger, pool, verifier, tel, categoryHandler)
+				if err != nil {
+					return nil, err
+				}
+				if categoryHandler != nil {
+					invalidateUserCategories = store.InvalidateUserCategories
+				}
+				purgeIdempotencyKeys = store.PurgeIdempotencyKeys
+				return h, nil
+			}))
+			lc.Append(purgeHook("idempotency-purge", svcLogger, cfg.User.Idempotency.PurgeInterval, func(ctx context.Context) (int64, error) {
+				return purgeIdempotencyKeys(ctx)
+			}))
 		}
-		fmt.Println("Gratefully shutting down user service")
 	}
 
+	return lc.Run(context.Background())
 }
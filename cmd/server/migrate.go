@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/opplieam/dist-mono/db/migrations"
+	"github.com/opplieam/dist-mono/internal/database"
+	"github.com/opplieam/dist-mono/internal/migrate"
+)
+
+const migrateUsage = "usage: -target migrate <up | down N | status | force VERSION>"
+
+func runMigrate(ctx context.Context, pool *database.Pool, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	m, err := migrate.New(pool.Pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Println("No pending migrations")
+			return nil
+		}
+		for _, mig := range done {
+			fmt.Printf("Applied %d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "down":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of migrations %q: %s", args[1], migrateUsage)
+		}
+		done, err := m.Down(ctx, n)
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Println("No applied migrations")
+			return nil
+		}
+		for _, mig := range done {
+			fmt.Printf("Rolled back %d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%06d_%s\t%s\n", st.Version, st.Name, state)
+		}
+		return nil
+	case "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %s", args[1], migrateUsage)
+		}
+		if err = m.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("Forced version %d\n", version)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q: %s", args[0], migrateUsage)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ogen-go/ogen/ogenerrors"
+	db "github.com/opplieam/dist-mono/db/sqlc"
+	"github.com/opplieam/dist-mono/internal/auth"
+	"github.com/opplieam/dist-mono/internal/cache"
+	catApi "github.com/opplieam/dist-mono/internal/category/api"
+	catHandler "github.com/opplieam/dist-mono/internal/category/handler"
+	catStore "github.com/opplieam/dist-mono/internal/category/store"
+	"github.com/opplieam/dist-mono/internal/config"
+	"github.com/opplieam/dist-mono/internal/database"
+	"github.com/opplieam/dist-mono/internal/health"
+	"github.com/opplieam/dist-mono/internal/inprocess"
+	"github.com/opplieam/dist-mono/internal/lifecycle"
+	"github.com/opplieam/dist-mono/internal/ratelimit"
+	"github.com/opplieam/dist-mono/internal/resilience"
+	"github.com/opplieam/dist-mono/internal/telemetry"
+	"github.com/opplieam/dist-mono/internal/tlsconfig"
+	userHandler "github.com/opplieam/dist-mono/internal/user/handler"
+	userStore "github.com/opplieam/dist-mono/internal/user/store"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// categoryAudience is the aud claim of the service tokens sent to the category service.
+const categoryAudience = "category"
+
+// serviceTelemetry holds the providers of the telemetry of one service, the global providers are
+// used while they are nil.
+type serviceTelemetry struct {
+	meterProvider  metric.MeterProvider
+	tracerProvider trace.TracerProvider
+}
+
+// service is an HTTP service handler managed by the lifecycle.
+type service interface {
+	Start() error
+	Serve() error
+	Drain()
+	Shutdown(ctx context.Context) error
+}
+
+// serviceHook builds the service with newService when the lifecycle starts it, so it can use
+// the dependencies started by the previous hooks.
+func serviceHook(name string, logger *slog.Logger, newService func() (service, error)) lifecycle.Hook {
+	var svc service
+	return lifecycle.Hook{
+		Name: name,
+		Start: func(context.Context) (err error) {
+			logger.Info("Starting " + name + " service")
+			if svc, err = newService(); err != nil {
+				return err
+			}
+			return svc.Start()
+		},
+		Serve: func() error {
+			return svc.Serve()
+		},
+		Drain: func() {
+			logger.Info("Shutting down " + name + " service")
+			svc.Drain()
+		},
+		Stop: func(ctx context.Context) error {
+			if err := svc.Shutdown(ctx); err != nil {
+				return err
+			}
+			logger.Info("Gratefully shutting down " + name + " service")
+			return nil
+		},
+	}
+}
+
+// purgeHook runs purge every interval while the lifecycle runs, purge returns how many rows it
+// deleted.
+func purgeHook(name string, logger *slog.Logger, interval time.Duration, purge func(ctx context.Context) (int64, error)) lifecycle.Hook {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	return lifecycle.Hook{
+		Name: name,
+		Serve: func() error {
+			defer close(done)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+					n, err := purge(ctx)
+					if err != nil && ctx.Err() == nil {
+						logger.Error("Purge failed", "component", name, "error", err)
+					} else if n > 0 {
+						logger.Info("Purged expired rows", "component", name, "deleted", n)
+					}
+				}
+			}
+		},
+		Stop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	}
+}
+
+// newCategoryService builds the category service, onUserCategoriesChanged is called after the
+// writes changing the categories of a user.
+func newCategoryService(cfg *config.Config, logger *slog.Logger, pool *database.Pool, verifier *auth.Verifier, tel *serviceTelemetry, onUserCategoriesChanged func(userID int)) (*catHandler.CategoryHandler, error) {
+	clientAuth := tls.NoClientCert
+	if cfg.Category.RequireClientCert {
+		clientAuth = tls.RequireAndVerifyClientCert
+	} else if cfg.TLS.CAFile != "" {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	tlsCfg, err := serverTLS(cfg.TLS, clientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	var callers *auth.CallerPolicy
+	if len(cfg.Category.AllowedCallers) > 0 {
+		var tokens *auth.Verifier
+		if cfg.Category.ServiceTokenJWKSFile != "" {
+			keys, err := auth.LoadKeySetFile(cfg.Category.ServiceTokenJWKSFile)
+			if err != nil {
+				return nil, err
+			}
+			tokens = auth.NewVerifier(keys, auth.Config{Audience: categoryAudience, Leeway: cfg.Auth.Leeway})
+		}
+		callers = auth.NewCallerPolicy(cfg.Category.AllowedCallers, tokens)
+	}
+
+	store := catStore.NewStore(db.New(pool), logger)
+	cHandler := catHandler.NewCategoryHandler(store, verifier, logger, catHandler.Config{
+		Addr:               cfg.Category.Addr,
+		HealthCheckTimeout: cfg.Health.CheckTimeout,
+		TLS:                tlsCfg,
+		Callers:            callers,
+		RateLimiter:        newRateLimiter(cfg.RateLimit, verifier, logger),
+		MeterProvider:      tel.meterProvider,
+		TracerProvider:     tel.tracerProvider,
+
+		OnUserCategoriesChanged: onUserCategoriesChanged,
+	})
+	cHandler.Health().Register(health.Check{Name: "db", Check: pool.Ping})
+	return cHandler, nil
+}
+
+// newUserService builds the user service and returns it with its store. When categoryHandler is
+// set the category service is called in-process through it, otherwise over HTTP at the configured
+// URL.
+func newUserService(cfg *config.Config, logger *slog.Logger, pool *database.Pool, verifier *auth.Verifier, tel *serviceTelemetry, categoryHandler http.Handler) (*userHandler.UserHandler, *userStore.Store, error) {
+	// networkTransport reaches the category service over the network, presenting the client
+	// certificate when mTLS is configured.
+	var networkTransport http.RoundTripper = http.DefaultTransport
+	if cfg.TLS.CertFile != "" || cfg.TLS.CAFile != "" {
+		tlsCfg, err := tlsconfig.Client(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlsCfg
+		networkTransport = t
+	}
+
+	var categoryTransport http.RoundTripper
+	if categoryHandler != nil {
+		// Retries and the circuit breaker guard against the network, which isn't involved here.
+		categoryTransport = inprocess.NewTransport(categoryHandler)
+	} else {
+		cc := cfg.User.CategoryClient
+		resilient, err := resilience.NewTransport("category", networkTransport, resilience.Config{
+			Timeout:                 cc.Timeout,
+			AttemptTimeout:          cc.AttemptTimeout,
+			MaxRetries:              cc.MaxRetries,
+			RetryBaseDelay:          cc.RetryBaseDelay,
+			RetryMaxDelay:           cc.RetryMaxDelay,
+			BreakerFailureThreshold: cc.BreakerFailureThreshold,
+			BreakerOpenTimeout:      cc.BreakerOpenTimeout,
+			BreakerHalfOpenProbes:   cc.BreakerHalfOpenProbes,
+			MeterProvider:           tel.meterProvider,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		categoryTransport = resilient
+	}
+	if tc := cfg.User.ServiceToken; tc.KeyFile != "" {
+		signer, err := auth.LoadSigner(tc.KeyFile, tc.KeyID, tc.Identity, categoryAudience, tc.TTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		categoryTransport = auth.NewSignerTransport(categoryTransport, signer)
+	}
+	categoryClient, err := catApi.NewClient(
+		cfg.User.CategoryURL,
+		forwardToken{},
+		catApi.WithClient(&http.Client{Transport: telemetry.NewTransport(categoryTransport)}),
+		catApi.WithMeterProvider(tel.meterProvider),
+		catApi.WithTracerProvider(tel.tracerProvider),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsCfg, err := serverTLS(cfg.TLS, tls.NoClientCert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store := userStore.NewStore(db.New(pool), categoryClient, logger).WithIdempotency(pool, cfg.User.Idempotency.TTL)
+	if cacheCfg := cfg.User.CategoryCache; cacheCfg.Size > 0 {
+		catCache, err := cache.NewLRU[int, userStore.CategoryEntry]("user-categories", cacheCfg.Size, tel.meterProvider)
+		if err != nil {
+			return nil, nil, err
+		}
+		store.WithCategoryCache(catCache, cacheCfg.TTL, cacheCfg.NegativeTTL)
+	}
+	uHandler := userHandler.NewUserHandler(store, verifier, logger, userHandler.Config{
+		Addr:               cfg.User.Addr,
+		HealthCheckTimeout: cfg.Health.CheckTimeout,
+		StrictCategory:     cfg.User.StrictCategory,
+		TLS:                tlsCfg,
+		RateLimiter:        newRateLimiter(cfg.RateLimit, verifier, logger),
+		MeterProvider:      tel.meterProvider,
+		TracerProvider:     tel.tracerProvider,
+	})
+
+	categoryHealthURL, err := url.JoinPath(cfg.User.CategoryURL, "..", "healthz")
+	if err != nil {
+		return nil, nil, err
+	}
+	healthTransport := networkTransport
+	if categoryHandler != nil {
+		healthTransport = categoryTransport
+	}
+	uHandler.Health().Register(health.Check{Name: "db", Check: pool.Ping})
+	// Without strict mode the user service degrades when the category service is down, so
+	// it stays ready and the check is only informative.
+	uHandler.Health().Register(health.Check{
+		Name:     "category",
+		Optional: !cfg.User.StrictCategory,
+		Check:    health.HTTPCheck(&http.Client{Transport: telemetry.NewTransport(healthTransport)}, categoryHealthURL),
+	})
+	return uHandler, store, nil
+}
+
+// serverTLS returns the TLS config of a service, nil to serve plain HTTP when no certificate is
+// configured.
+func serverTLS(cfg config.TLSConfig, clientAuth tls.ClientAuthType) (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	return tlsconfig.Server(cfg.CertFile, cfg.KeyFile, cfg.CAFile, clientAuth)
+}
+
+// newRateLimiter returns the in-memory rate limiter of a service, nil when rate limiting is
+// disabled.
+func newRateLimiter(cfg config.RateLimitConfig, verifier *auth.Verifier, logger *slog.Logger) *ratelimit.Limiter {
+	if cfg.Rate == 0 && len(cfg.Operations) == 0 {
+		return nil
+	}
+	limits := ratelimit.Config{
+		Default:    ratelimit.Limit{Rate: cfg.Rate, Burst: cfg.Burst},
+		Operations: make(map[string]ratelimit.Limit, len(cfg.Operations)),
+	}
+	for op, limit := range cfg.Operations {
+		limits.Operations[op] = ratelimit.Limit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+	return ratelimit.New(ratelimit.NewMemory(), limits, ratelimit.ClientKey(cfg.APIKeyHeader, verifier), logger)
+}
+
+// newVerifier builds the bearer token verifier from the local JWKS file or the JWKS endpoint.
+func newVerifier(cfg config.AuthConfig) (*auth.Verifier, error) {
+	var keys auth.KeySet
+	if cfg.JWKSFile != "" {
+		fileKeys, err := auth.LoadKeySetFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = fileKeys
+	} else {
+		client := &http.Client{Timeout: cfg.JWKSTimeout, Transport: telemetry.NewTransport(http.DefaultTransport)}
+		keys = auth.NewRemoteKeySet(cfg.JWKSURL, client, cfg.JWKSRefresh, cfg.JWKSMinRefresh)
+	}
+	return auth.NewVerifier(keys, auth.Config{
+		Issuer:   cfg.Issuer,
+		Audience: cfg.Audience,
+		Leeway:   cfg.Leeway,
+	}), nil
+}
+
+// forwardToken authenticates the category service calls with the bearer token of the request
+// being served.
+type forwardToken struct{}
+
+func (forwardToken) BearerAuth(ctx context.Context, _ catApi.OperationName) (catApi.BearerAuth, error) {
+	token, ok := auth.TokenFromContext(ctx)
+	if !ok {
+		return catApi.BearerAuth{}, ogenerrors.ErrSkipClientSecurity
+	}
+	return catApi.BearerAuth{Token: token}, nil
+}
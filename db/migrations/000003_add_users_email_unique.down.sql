@@ -0,0 +1,3 @@
+-- The emails stay normalized: their original casing and whitespace aren't kept by the up
+-- migration, so they can't be restored.
+DROP INDEX IF EXISTS users_email_lower_key;
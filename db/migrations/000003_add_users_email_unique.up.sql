@@ -0,0 +1,26 @@
+-- Precondition: no two users may share an email once trimmed and lowercased. The migration
+-- aborts listing them otherwise, they have to be merged or renamed by hand before it is rerun.
+DO $$
+DECLARE
+    duplicates text;
+BEGIN
+    SELECT string_agg(format('%s (ids %s)', email, ids), ', ')
+    INTO duplicates
+    FROM (
+        SELECT lower(btrim(email)) AS email, string_agg(id::text, ', ' ORDER BY id) AS ids
+        FROM users
+        GROUP BY lower(btrim(email))
+        HAVING count(*) > 1
+    ) d;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'users share an email ignoring case, resolve them first: %', duplicates;
+    END IF;
+END
+$$;
+
+UPDATE users
+SET email = lower(btrim(email))
+WHERE email <> lower(btrim(email));
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users (lower(email));
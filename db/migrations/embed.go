@@ -0,0 +1,7 @@
+// Package migrations embeds the SQL migration files so the server binary can apply them.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
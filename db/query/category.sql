@@ -1,4 +1,36 @@
 -- name: GetCategoryByID :one
-SELECT id, name
+SELECT id, name, user_id
 FROM categorys
-WHERE user_id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: ListCategories :many
+SELECT id, name, user_id
+FROM categorys
+ORDER BY id;
+
+-- name: CreateCategory :one
+INSERT INTO categorys (name, user_id)
+VALUES ($1, $2) RETURNING id, name, user_id;
+
+-- name: UpdateCategory :one
+UPDATE categorys
+SET name    = $2,
+    user_id = $3
+WHERE id = $1 RETURNING id, name, user_id;
+
+-- name: PatchCategory :one
+UPDATE categorys
+SET name    = COALESCE(sqlc.narg(name), name),
+    user_id = COALESCE(sqlc.narg(user_id), user_id)
+WHERE id = sqlc.arg(id) RETURNING id, name, user_id;
+
+-- name: DeleteCategory :execrows
+DELETE
+FROM categorys
+WHERE id = $1;
+
+-- name: ListCategoriesByUserID :many
+SELECT id, name, user_id
+FROM categorys
+WHERE user_id = $1
+ORDER BY id;
@@ -0,0 +1,27 @@
+-- name: ClaimIdempotencyKey :execrows
+INSERT INTO idempotency_keys (client, operation, key, request_hash)
+VALUES (sqlc.arg(client), sqlc.arg(operation), sqlc.arg(key), sqlc.arg(request_hash))
+ON CONFLICT (client, operation, key) DO UPDATE
+    SET request_hash = EXCLUDED.request_hash,
+        response     = NULL,
+        created_at   = now()
+    WHERE idempotency_keys.created_at < sqlc.arg(expired_before);
+
+-- name: GetIdempotencyKey :one
+SELECT client, operation, key, request_hash, response, created_at
+FROM idempotency_keys
+WHERE client = $1
+  AND operation = $2
+  AND key = $3;
+
+-- name: SaveIdempotencyResponse :exec
+UPDATE idempotency_keys
+SET response = $4
+WHERE client = $1
+  AND operation = $2
+  AND key = $3;
+
+-- name: PurgeIdempotencyKeys :execrows
+DELETE
+FROM idempotency_keys
+WHERE created_at < $1;
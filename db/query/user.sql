@@ -1,12 +1,39 @@
 -- name: GetAllUsers :many
-SELECT id, name, email
-FROM users;
+SELECT id, name, email, created_at, updated_at
+FROM users
+WHERE (sqlc.narg(cursor)::int IS NULL
+    OR (sqlc.arg(sort_desc)::bool AND id < sqlc.narg(cursor)::int)
+    OR (NOT sqlc.arg(sort_desc)::bool AND id > sqlc.narg(cursor)::int))
+  AND (sqlc.narg(name)::varchar IS NULL OR strpos(lower(name), lower(sqlc.narg(name)::varchar)) > 0)
+  AND (sqlc.narg(email)::varchar IS NULL OR lower(email) = lower(sqlc.narg(email)::varchar))
+ORDER BY CASE WHEN sqlc.arg(sort_desc)::bool THEN id END DESC,
+         id
+LIMIT sqlc.narg(page_limit)::int;
 
 -- name: CreateUser :one
 INSERT INTO users (name, email)
-VALUES ($1, $2) RETURNING id;
+VALUES ($1, $2) RETURNING id, name, email, created_at, updated_at;
 
 -- name: GetUserByID :one
-SELECT id, name, email
+SELECT id, name, email, created_at, updated_at
+FROM users
+WHERE id = $1;
+
+-- name: UpdateUser :one
+UPDATE users
+SET name       = $2,
+    email      = $3,
+    updated_at = now()
+WHERE id = $1 RETURNING id, name, email, created_at, updated_at;
+
+-- name: PatchUser :one
+UPDATE users
+SET name       = COALESCE(sqlc.narg(name), name),
+    email      = COALESCE(sqlc.narg(email), email),
+    updated_at = now()
+WHERE id = sqlc.arg(id) RETURNING id, name, email, created_at, updated_at;
+
+-- name: DeleteUser :execrows
+DELETE
 FROM users
 WHERE id = $1;
@@ -7,22 +7,143 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const createCategory = `-- name: CreateCategory :one
+INSERT INTO categorys (name, user_id)
+VALUES ($1, $2) RETURNING id, name, user_id
+`
+
+type CreateCategoryParams struct {
+	Name   string
+	UserID int32
+}
+
+func (q *Queries) CreateCategory(ctx context.Context, arg CreateCategoryParams) (Category, error) {
+	row := q.db.QueryRow(ctx, createCategory, arg.Name, arg.UserID)
+	var i Category
+	err := row.Scan(&i.ID, &i.Name, &i.UserID)
+	return i, err
+}
+
+const deleteCategory = `-- name: DeleteCategory :execrows
+DELETE
+FROM categorys
+WHERE id = $1
+`
+
+func (q *Queries) DeleteCategory(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteCategory, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getCategoryByID = `-- name: GetCategoryByID :one
-SELECT id, name
+SELECT id, name, user_id
+FROM categorys
+WHERE id = $1
+`
+
+func (q *Queries) GetCategoryByID(ctx context.Context, id int32) (Category, error) {
+	row := q.db.QueryRow(ctx, getCategoryByID, id)
+	var i Category
+	err := row.Scan(&i.ID, &i.Name, &i.UserID)
+	return i, err
+}
+
+const listCategories = `-- name: ListCategories :many
+SELECT id, name, user_id
+FROM categorys
+ORDER BY id
+`
+
+func (q *Queries) ListCategories(ctx context.Context) ([]Category, error) {
+	rows, err := q.db.Query(ctx, listCategories)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Category
+	for rows.Next() {
+		var i Category
+		if err := rows.Scan(&i.ID, &i.Name, &i.UserID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCategoriesByUserID = `-- name: ListCategoriesByUserID :many
+SELECT id, name, user_id
 FROM categorys
 WHERE user_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ListCategoriesByUserID(ctx context.Context, userID int32) ([]Category, error) {
+	rows, err := q.db.Query(ctx, listCategoriesByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Category
+	for rows.Next() {
+		var i Category
+		if err := rows.Scan(&i.ID, &i.Name, &i.UserID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const patchCategory = `-- name: PatchCategory :one
+UPDATE categorys
+SET name    = COALESCE($1, name),
+    user_id = COALESCE($2, user_id)
+WHERE id = $3 RETURNING id, name, user_id
+`
+
+type PatchCategoryParams struct {
+	Name   pgtype.Text
+	UserID pgtype.Int4
+	ID     int32
+}
+
+func (q *Queries) PatchCategory(ctx context.Context, arg PatchCategoryParams) (Category, error) {
+	row := q.db.QueryRow(ctx, patchCategory, arg.Name, arg.UserID, arg.ID)
+	var i Category
+	err := row.Scan(&i.ID, &i.Name, &i.UserID)
+	return i, err
+}
+
+const updateCategory = `-- name: UpdateCategory :one
+UPDATE categorys
+SET name    = $2,
+    user_id = $3
+WHERE id = $1 RETURNING id, name, user_id
 `
 
-type GetCategoryByIDRow struct {
-	ID   int32
-	Name string
+type UpdateCategoryParams struct {
+	ID     int32
+	Name   string
+	UserID int32
 }
 
-func (q *Queries) GetCategoryByID(ctx context.Context, userID int32) (GetCategoryByIDRow, error) {
-	row := q.db.QueryRow(ctx, getCategoryByID, userID)
-	var i GetCategoryByIDRow
-	err := row.Scan(&i.ID, &i.Name)
+func (q *Queries) UpdateCategory(ctx context.Context, arg UpdateCategoryParams) (Category, error) {
+	row := q.db.QueryRow(ctx, updateCategory, arg.ID, arg.Name, arg.UserID)
+	var i Category
+	err := row.Scan(&i.ID, &i.Name, &i.UserID)
 	return i, err
 }
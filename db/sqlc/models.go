@@ -4,14 +4,29 @@
 
 package db
 
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
 type Category struct {
 	ID     int32
 	Name   string
 	UserID int32
 }
 
+type IdempotencyKey struct {
+	Client      string
+	Operation   string
+	Key         string
+	RequestHash string
+	Response    []byte
+	CreatedAt   pgtype.Timestamptz
+}
+
 type User struct {
-	ID    int32
-	Name  string
-	Email string
+	ID        int32
+	Name      string
+	Email     string
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
 }
@@ -7,11 +7,13 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (name, email)
-VALUES ($1, $2) RETURNING id
+VALUES ($1, $2) RETURNING id, name, email, created_at, updated_at
 `
 
 type CreateUserParams struct {
@@ -19,20 +21,62 @@ type CreateUserParams struct {
 	Email string
 }
 
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (int32, error) {
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
 	row := q.db.QueryRow(ctx, createUser, arg.Name, arg.Email)
-	var id int32
-	err := row.Scan(&id)
-	return id, err
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const getAllUsers = `-- name: GetAllUsers :many
-SELECT id, name, email
+SELECT id, name, email, created_at, updated_at
 FROM users
+WHERE ($1::int IS NULL
+    OR ($2::bool AND id < $1::int)
+    OR (NOT $2::bool AND id > $1::int))
+  AND ($3::varchar IS NULL OR strpos(lower(name), lower($3::varchar)) > 0)
+  AND ($4::varchar IS NULL OR lower(email) = lower($4::varchar))
+ORDER BY CASE WHEN $2::bool THEN id END DESC,
+         id
+LIMIT $5::int
 `
 
-func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.Query(ctx, getAllUsers)
+type GetAllUsersParams struct {
+	Cursor    pgtype.Int4
+	SortDesc  bool
+	Name      pgtype.Text
+	Email     pgtype.Text
+	PageLimit pgtype.Int4
+}
+
+func (q *Queries) GetAllUsers(ctx context.Context, arg GetAllUsersParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, getAllUsers,
+		arg.Cursor,
+		arg.SortDesc,
+		arg.Name,
+		arg.Email,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +84,13 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 	var items []User
 	for rows.Next() {
 		var i User
-		if err := rows.Scan(&i.ID, &i.Name, &i.Email); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -52,7 +102,7 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, name, email
+SELECT id, name, email, created_at, updated_at
 FROM users
 WHERE id = $1
 `
@@ -60,6 +110,66 @@ WHERE id = $1
 func (q *Queries) GetUserByID(ctx context.Context, id int32) (User, error) {
 	row := q.db.QueryRow(ctx, getUserByID, id)
 	var i User
-	err := row.Scan(&i.ID, &i.Name, &i.Email)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const patchUser = `-- name: PatchUser :one
+UPDATE users
+SET name       = COALESCE($1, name),
+    email      = COALESCE($2, email),
+    updated_at = now()
+WHERE id = $3 RETURNING id, name, email, created_at, updated_at
+`
+
+type PatchUserParams struct {
+	Name  pgtype.Text
+	Email pgtype.Text
+	ID    int32
+}
+
+func (q *Queries) PatchUser(ctx context.Context, arg PatchUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, patchUser, arg.Name, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET name       = $2,
+    email      = $3,
+    updated_at = now()
+WHERE id = $1 RETURNING id, name, email, created_at, updated_at
+`
+
+type UpdateUserParams struct {
+	ID    int32
+	Name  string
+	Email string
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUser, arg.ID, arg.Name, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
 	return i, err
 }
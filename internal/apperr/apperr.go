@@ -0,0 +1,161 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Kind classifies an error by how it is reported to clients.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindNotFound
+	KindConflict
+	KindUnavailable
+	KindUnauthenticated
+	KindForbidden
+	KindRateLimited
+	KindUnprocessable
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	pgNotNullViolation     = "23502"
+	pgForeignKeyViolation  = "23503"
+	pgUniqueViolation      = "23505"
+	pgCheckViolation       = "23514"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+func (k Kind) StatusCode() int {
+	switch k {
+	case KindInvalid:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindUnauthenticated:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindRateLimited:
+		return http.StatusTooManyRequests
+	case KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// String is used as the type attribute of the error metrics.
+func (k Kind) String() string {
+	switch k {
+	case KindInvalid:
+		return "invalid"
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindUnavailable:
+		return "dependency_failure"
+	case KindUnauthenticated:
+		return "unauthenticated"
+	case KindForbidden:
+		return "forbidden"
+	case KindRateLimited:
+		return "rate_limited"
+	case KindUnprocessable:
+		return "unprocessable"
+	default:
+		return "internal"
+	}
+}
+
+// Error is a domain error. Message is safe to return to clients, while the wrapped Err keeps the
+// internal detail for logs and spans.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same kind and message, so a sentinel still matches once it carries
+// an internal cause.
+func (e *Error) Is(target error) bool {
+	var t *Error
+	if !errors.As(target, &t) {
+		return false
+	}
+	return t.Err == nil && e.Kind == t.Kind && e.Message == t.Message
+}
+
+// Wrap returns a copy of the sentinel e carrying err as its internal cause.
+func (e *Error) Wrap(err error) *Error {
+	return &Error{Kind: e.Kind, Message: e.Message, Err: err}
+}
+
+// KindOf returns the kind of err, it is KindInternal unless err wraps a domain error.
+func KindOf(err error) Kind {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Kind
+	}
+	return KindInternal
+}
+
+// IsUniqueViolation reports whether err is a Postgres violation of the unique constraint or
+// index named constraint.
+func IsUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == constraint
+}
+
+// Translate turns any error into a domain error. Domain errors are returned as is, Postgres
+// constraint violations and serialization failures are mapped to their kind, and everything
+// else is internal.
+func Translate(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return &Error{Kind: KindConflict, Message: "resource already exists", Err: err}
+		case pgForeignKeyViolation:
+			return &Error{Kind: KindInvalid, Message: "referenced resource does not exist", Err: err}
+		case pgNotNullViolation:
+			return &Error{Kind: KindInvalid, Message: "required field is missing", Err: err}
+		case pgCheckViolation:
+			return &Error{Kind: KindInvalid, Message: "field has an invalid value", Err: err}
+		case pgSerializationFailure, pgDeadlockDetected:
+			return &Error{Kind: KindConflict, Message: "concurrent update conflict, please retry", Err: err}
+		}
+	}
+	return &Error{Kind: KindInternal, Message: "internal server error", Err: err}
+}
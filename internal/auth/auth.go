@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/opplieam/dist-mono/internal/apperr"
+)
+
+var ErrUnauthenticated = apperr.New(apperr.KindUnauthenticated, "missing or invalid bearer token")
+
+// Claims are the claims of a verified access token.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+type Config struct {
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew on the exp, nbf and iat checks.
+	Leeway time.Duration
+}
+
+// Verifier verifies bearer JWTs against a key set and checks their issuer, audience and
+// validity period.
+type Verifier struct {
+	keys   KeySet
+	parser *jwt.Parser
+}
+
+func NewVerifier(keys KeySet, cfg Config) *Verifier {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	return &Verifier{
+		keys:   keys,
+		parser: jwt.NewParser(opts...),
+	}
+}
+
+// Verify returns the claims of token. Every failure is an ErrUnauthenticated carrying the
+// reason as its internal cause, except ErrKeysUnavailable when the signing keys can't be fetched.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := v.keys.Key(ctx, kid)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+		return key, nil
+	})
+	if errors.Is(err, ErrKeysUnavailable) {
+		return nil, ErrKeysUnavailable.Wrap(err)
+	}
+	if err != nil {
+		return nil, ErrUnauthenticated.Wrap(err)
+	}
+	return &claims, nil
+}
+
+// verification is the outcome of the verification of a request's bearer token by a verifier.
+type verification struct {
+	verifier *Verifier
+	token    string
+	claims   *Claims
+	err      error
+}
+
+type verificationKey struct{}
+
+// Authenticate verifies the bearer token of the request, when it has one, so the middlewares
+// running before the security handler can identify the caller. The outcome is kept in the
+// request context, VerifyContext reuses it instead of verifying the token again. Requests are
+// never rejected here, that is left to the security handler.
+func (v *Verifier) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, err := v.Verify(r.Context(), token)
+		ctx := context.WithValue(r.Context(), verificationKey{}, &verification{verifier: v, token: token, claims: claims, err: err})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// VerifyContext is Verify reusing the outcome of Authenticate when v already verified token for
+// the request of ctx.
+func (v *Verifier) VerifyContext(ctx context.Context, token string) (*Claims, error) {
+	if vr, ok := ctx.Value(verificationKey{}).(*verification); ok && vr.verifier == v && vr.token == token {
+		return vr.claims, vr.err
+	}
+	return v.Verify(ctx, token)
+}
+
+type claimsKey struct{}
+
+type tokenKey struct{}
+
+// WithClaims returns a context carrying the verified claims and the raw token they came from.
+func WithClaims(ctx context.Context, claims *Claims, token string) context.Context {
+	ctx = context.WithValue(ctx, claimsKey{}, claims)
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// TokenFromContext returns the bearer token of the request, so it can be forwarded to
+// downstream services.
+func TokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(tokenKey{}).(string)
+	return token, ok
+}
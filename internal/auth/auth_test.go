@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/opplieam/dist-mono/internal/apperr"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "dist-mono"
+)
+
+func rsaJWK(t *testing.T, kid string, key *rsa.PrivateKey) map[string]string {
+	t.Helper()
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(t *testing.T, kid string, key *ecdsa.PrivateKey) map[string]string {
+	t.Helper()
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func jwks(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   "42",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"roles": []string{"admin"},
+	}
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, jwks(t, rsaJWK(t, "rsa", rsaKey), ecJWK(t, "ec", ecKey)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadKeySetFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(keys, Config{Issuer: testIssuer, Audience: testAudience})
+
+	with := func(key string, value any) jwt.MapClaims {
+		c := validClaims()
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid rsa", sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, validClaims()), false},
+		{"valid ec", sign(t, jwt.SigningMethodES256, "ec", ecKey, validClaims()), false},
+		{"expired", sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with("exp", time.Now().Add(-time.Hour).Unix())), true},
+		{"missing exp", sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with("exp", nil)), true},
+		{"not yet valid", sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with("nbf", time.Now().Add(time.Hour).Unix())), true},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with("iss", "https://evil.example.com")), true},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with("aud", "other")), true},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "unknown", rsaKey, validClaims()), true},
+		{"wrong signing key", sign(t, jwt.SigningMethodRS256, "rsa", otherKey, validClaims()), true},
+		{"hmac with the public key", sign(t, jwt.SigningMethodHS256, "rsa", []byte("secret"), validClaims()), true},
+		{"malformed", "not.a.token", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				if !errors.Is(err, ErrUnauthenticated) || apperr.KindOf(err) != apperr.KindUnauthenticated {
+					t.Errorf("error %q is not ErrUnauthenticated", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if claims.Subject != "42" || len(claims.Roles) != 1 || claims.Roles[0] != "admin" {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestRemoteKeySetRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body atomic.Value
+	body.Store(jwks(t, rsaJWK(t, "old", oldKey)))
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(body.Load().([]byte))
+	}))
+	defer srv.Close()
+
+	keys := NewRemoteKeySet(srv.URL, srv.Client(), time.Hour, 0)
+	v := NewVerifier(keys, Config{Issuer: testIssuer, Audience: testAudience})
+
+	if _, err = v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "old", oldKey, validClaims())); err != nil {
+		t.Fatalf("old key: %v", err)
+	}
+	if _, err = v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "old", oldKey, validClaims())); err != nil {
+		t.Fatalf("old key, cached: %v", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+
+	// The provider rotates its key, the unknown kid triggers a refresh.
+	body.Store(jwks(t, rsaJWK(t, "new", newKey)))
+	if _, err = v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "new", newKey, validClaims())); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("fetches = %d, want 2", n)
+	}
+}
+
+func TestRemoteKeySetUnavailable(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var down atomic.Bool
+	down.Store(true)
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write(jwks(t, rsaJWK(t, "rsa", rsaKey)))
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	keys := NewRemoteKeySet(srv.URL, srv.Client(), time.Hour, time.Minute)
+	keys.now = func() time.Time { return now }
+	v := NewVerifier(keys, Config{Issuer: testIssuer, Audience: testAudience})
+	token := sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, validClaims())
+
+	// A failed fetch isn't the client's fault, and isn't retried before minRefreshInterval.
+	for range 2 {
+		_, err = v.Verify(context.Background(), token)
+		if !errors.Is(err, ErrKeysUnavailable) || apperr.KindOf(err) != apperr.KindUnavailable {
+			t.Fatalf("endpoint down: error = %v, want ErrKeysUnavailable", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+
+	down.Store(false)
+	now = now.Add(time.Minute)
+	if _, err = v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("endpoint back: %v", err)
+	}
+
+	// Once the cache is stale, the cached keys are served while the endpoint is down.
+	down.Store(true)
+	now = now.Add(time.Hour)
+	for range 2 {
+		if _, err = v.Verify(context.Background(), token); err != nil {
+			t.Fatalf("stale keys: %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 3 {
+		t.Errorf("fetches = %d, want 3", n)
+	}
+}
+
+// countingKeySet counts the key lookups, one per verified token.
+type countingKeySet struct {
+	key     crypto.PublicKey
+	lookups int
+}
+
+func (k *countingKeySet) Key(context.Context, string) (crypto.PublicKey, error) {
+	k.lookups++
+	return k.key, nil
+}
+
+func TestVerifyContext(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &countingKeySet{key: &rsaKey.PublicKey}
+	v := NewVerifier(keys, Config{Issuer: testIssuer, Audience: testAudience})
+	other := NewVerifier(keys, Config{Issuer: testIssuer, Audience: testAudience})
+	token := sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, validClaims())
+
+	var ctx context.Context
+	handler := v.Authenticate(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if keys.lookups != 1 {
+		t.Fatalf("Authenticate verified the token %d times, want 1", keys.lookups)
+	}
+
+	claims, err := v.VerifyContext(ctx, token)
+	if err != nil || claims.Subject != "42" {
+		t.Fatalf("VerifyContext = %+v, %v", claims, err)
+	}
+	if keys.lookups != 1 {
+		t.Errorf("VerifyContext verified the token again")
+	}
+
+	// Another verifier, or another token, isn't trusted from the context.
+	if _, err = other.VerifyContext(ctx, token); err != nil || keys.lookups != 2 {
+		t.Errorf("other verifier: error %v after %d lookups, want a new verification", err, keys.lookups)
+	}
+	if _, err = v.VerifyContext(ctx, "not.a.token"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("other token: error = %v, want ErrUnauthenticated", err)
+	}
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/opplieam/dist-mono/internal/apperr"
+	"golang.org/x/sync/singleflight"
+)
+
+// KeySet resolves the public key a token was signed with from its kid header.
+type KeySet interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+var errUnknownKey = errors.New("unknown signing key")
+
+// ErrKeysUnavailable is returned when the key set can't be fetched, the token may well be valid.
+var ErrKeysUnavailable = apperr.New(apperr.KindUnavailable, "authentication is temporarily unavailable")
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS parses a JSON Web Key Set into public keys by kid. Keys that aren't signature keys
+// or use an unsupported type are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no usable signing key")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// StaticKeySet is a key set loaded once, e.g. from a local file.
+type StaticKeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+func LoadKeySetFile(path string) (*StaticKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jwks file: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeySet{keys: keys}, nil
+}
+
+func (s *StaticKeySet) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+// RemoteKeySet fetches a key set from a JWKS endpoint and caches it for refreshInterval. An
+// unknown kid triggers an early refresh. Fetches, failed or not, are attempted at most once per
+// minRefreshInterval, so rotated keys are picked up without letting bogus tokens or an outage
+// hammer the endpoint.
+type RemoteKeySet struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	now                func() time.Time
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// lastErr is the error of the last attempt, nil when it succeeded.
+	lastErr error
+	group   singleflight.Group
+}
+
+func NewRemoteKeySet(url string, client *http.Client, refreshInterval, minRefreshInterval time.Duration) *RemoteKeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &RemoteKeySet{
+		url:                url,
+		client:             client,
+		refreshInterval:    refreshInterval,
+		minRefreshInterval: minRefreshInterval,
+		now:                time.Now,
+	}
+}
+
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	now := s.now()
+	age := now.Sub(s.fetchedAt)
+	throttled := !s.lastAttempt.IsZero() && now.Sub(s.lastAttempt) < s.minRefreshInterval
+	lastErr := s.lastErr
+	s.mu.RUnlock()
+
+	switch {
+	case ok && (age < s.refreshInterval || throttled):
+		return key, nil
+	case throttled && lastErr != nil:
+		return nil, lastErr
+	case throttled:
+		return nil, errUnknownKey
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		// Keep serving the cached keys while the endpoint is unavailable.
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok = s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+// refresh fetches the key set, concurrent callers share a single request.
+func (s *RemoteKeySet) refresh(ctx context.Context) error {
+	_, err, _ := s.group.Do("", func() (interface{}, error) {
+		keys, err := s.fetch(context.WithoutCancel(ctx))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.lastAttempt = s.now()
+		if err != nil {
+			s.lastErr = ErrKeysUnavailable.Wrap(err)
+			return nil, s.lastErr
+		}
+		s.keys, s.fetchedAt, s.lastErr = keys, s.lastAttempt, nil
+		return nil, nil
+	})
+	return err
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	return parseJWKS(data)
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/opplieam/dist-mono/internal/apperr"
+)
+
+// ServiceTokenHeader carries the service token, Authorization keeps the end user's token.
+const ServiceTokenHeader = "X-Service-Token"
+
+var (
+	ErrNoCallerIdentity = apperr.New(apperr.KindUnauthenticated, "missing or invalid caller credentials")
+	ErrCallerNotAllowed = apperr.New(apperr.KindForbidden, "caller is not allowed")
+)
+
+// Signer issues short-lived service tokens identifying this service to another one. A token is
+// reused until half of its lifetime has passed.
+type Signer struct {
+	key      crypto.Signer
+	method   jwt.SigningMethod
+	kid      string
+	identity string
+	audience string
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	renewAt time.Time
+}
+
+// LoadSigner reads a PEM private key (PKCS#8, PKCS#1 or SEC 1). The tokens have identity as
+// their iss and sub claims and audience as their aud claim.
+func LoadSigner(keyFile, kid, identity, audience string, ttl time.Duration) (*Signer, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read service token key: %w", err)
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("service token key: %w", err)
+	}
+	method, err := signingMethod(key)
+	if err != nil {
+		return nil, fmt.Errorf("service token key: %w", err)
+	}
+	return &Signer{
+		key:      key,
+		method:   method,
+		kid:      kid,
+		identity: identity,
+		audience: audience,
+		ttl:      ttl,
+		now:      time.Now,
+	}, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported private key format")
+}
+
+func signingMethod(key crypto.Signer) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return jwt.SigningMethodES256, nil
+		case 384:
+			return jwt.SigningMethodES384, nil
+		case 521:
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+func (s *Signer) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.token != "" && now.Before(s.renewAt) {
+		return s.token, nil
+	}
+	token := jwt.NewWithClaims(s.method, jwt.RegisteredClaims{
+		Issuer:    s.identity,
+		Subject:   s.identity,
+		Audience:  jwt.ClaimStrings{s.audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+	})
+	token.Header["kid"] = s.kid
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("sign service token: %w", err)
+	}
+	s.token, s.renewAt = signed, now.Add(s.ttl/2)
+	return signed, nil
+}
+
+// SignerTransport is an http.RoundTripper adding a service token to every request.
+type SignerTransport struct {
+	base   http.RoundTripper
+	signer *Signer
+}
+
+func NewSignerTransport(base http.RoundTripper, signer *Signer) *SignerTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &SignerTransport{base: base, signer: signer}
+}
+
+func (t *SignerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.signer.Token()
+	if err != nil {
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set(ServiceTokenHeader, token)
+	return t.base.RoundTrip(r)
+}
+
+// CallerPolicy lets through the requests of allowlisted services, identified by a verified
+// client certificate or a service token.
+type CallerPolicy struct {
+	allowed map[string]bool
+	// tokens verifies the service tokens, nil when they aren't accepted.
+	tokens *Verifier
+}
+
+func NewCallerPolicy(allowed []string, tokens *Verifier) *CallerPolicy {
+	p := &CallerPolicy{allowed: make(map[string]bool, len(allowed)), tokens: tokens}
+	for _, id := range allowed {
+		p.allowed[id] = true
+	}
+	return p
+}
+
+// Caller returns the allowlisted identity of the caller. The identities of a client certificate
+// are its URI and DNS SANs and its common name.
+func (p *CallerPolicy) Caller(r *http.Request) (string, error) {
+	var seen []string
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		for _, uri := range cert.URIs {
+			seen = append(seen, uri.String())
+		}
+		seen = append(seen, cert.DNSNames...)
+		if cert.Subject.CommonName != "" {
+			seen = append(seen, cert.Subject.CommonName)
+		}
+	}
+	if token := r.Header.Get(ServiceTokenHeader); token != "" && p.tokens != nil {
+		claims, err := p.tokens.Verify(r.Context(), token)
+		if err != nil {
+			return "", ErrNoCallerIdentity.Wrap(err)
+		}
+		seen = append(seen, claims.Subject)
+	}
+
+	if len(seen) == 0 {
+		return "", ErrNoCallerIdentity
+	}
+	for _, id := range seen {
+		if p.allowed[id] {
+			return id, nil
+		}
+	}
+	return "", ErrCallerNotAllowed.Wrap(fmt.Errorf("caller identities %v", seen))
+}
+
+type callerKey struct{}
+
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the identity of the service that made the request.
+func CallerFromContext(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(callerKey{}).(string)
+	return caller, ok
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opplieam/dist-mono/internal/apperr"
+)
+
+func writeSigner(t *testing.T, key *ecdsa.PrivateKey, identity string) *Signer {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSigner(path, "svc", identity, "category", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCallerPolicy(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, jwks(t, ecJWK(t, "svc", key)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadKeySetFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := NewCallerPolicy([]string{"user"}, NewVerifier(keys, Config{Audience: "category"}))
+
+	token := func(s *Signer) string {
+		tok, err := s.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	userSigner := writeSigner(t, key, "user")
+	if token(userSigner) != token(userSigner) {
+		t.Error("signer did not reuse its token")
+	}
+	wrongAudience := writeSigner(t, key, "user")
+	wrongAudience.audience = "other"
+
+	tests := []struct {
+		name       string
+		token      string
+		certName   string
+		wantCaller string
+		wantKind   apperr.Kind
+	}{
+		{name: "service token", token: token(userSigner), wantCaller: "user"},
+		{name: "client certificate", certName: "user", wantCaller: "user"},
+		{name: "token of another service", token: token(writeSigner(t, key, "billing")), wantKind: apperr.KindForbidden},
+		{name: "certificate of another service", certName: "billing", wantKind: apperr.KindForbidden},
+		{name: "token for another audience", token: token(wrongAudience), wantKind: apperr.KindUnauthenticated},
+		{name: "no credentials", wantKind: apperr.KindUnauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequestWithContext(context.Background(), "GET", "/v1/category", nil)
+			if tt.token != "" {
+				r.Header.Set(ServiceTokenHeader, tt.token)
+			}
+			if tt.certName != "" {
+				cert := &x509.Certificate{Subject: pkix.Name{CommonName: tt.certName}}
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+			}
+
+			caller, err := policy.Caller(r)
+			if tt.wantCaller != "" {
+				if err != nil || caller != tt.wantCaller {
+					t.Fatalf("Caller() = %q, %v, want %q", caller, err, tt.wantCaller)
+				}
+				return
+			}
+			var appErr *apperr.Error
+			if !errors.As(err, &appErr) || appErr.Kind != tt.wantKind {
+				t.Fatalf("Caller() error = %v, want kind %s", err, tt.wantKind)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/opplieam/dist-mono/internal/apperr"
+	"github.com/opplieam/dist-mono/internal/auth"
+)
+
+// RoleAdmin is the role, from the roles claim of the access token, allowed every operation.
+const RoleAdmin = "admin"
+
+var ErrForbidden = apperr.New(apperr.KindForbidden, "permission denied")
+
+// Policy decides whether the caller of a request may perform an operation, from the claims of
+// its access token. Every denial is audit logged.
+type Policy struct {
+	log *slog.Logger
+}
+
+func NewPolicy(log *slog.Logger) *Policy {
+	return &Policy{log: log}
+}
+
+// HasRole reports whether the caller has role.
+func (p *Policy) HasRole(ctx context.Context, role string) bool {
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok {
+		return false
+	}
+	for _, r := range claims.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole allows the callers having role.
+func (p *Policy) RequireRole(ctx context.Context, operation, role string) error {
+	if p.HasRole(ctx, role) {
+		return nil
+	}
+	return p.deny(ctx, operation, fmt.Errorf("missing role %q", role), "role", role)
+}
+
+// RequireOwner allows the admins and the user identified by every one of ownerIDs, the subject
+// of a user's token being their user id.
+func (p *Policy) RequireOwner(ctx context.Context, operation string, ownerIDs ...int) error {
+	if p.HasRole(ctx, RoleAdmin) {
+		return nil
+	}
+	claims, _ := auth.ClaimsFromContext(ctx)
+	for _, id := range ownerIDs {
+		if claims == nil || claims.Subject != strconv.Itoa(id) {
+			return p.deny(ctx, operation, fmt.Errorf("not the owner of user %d resources", id), "owner_id", id)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) deny(ctx context.Context, operation string, reason error, args ...any) error {
+	var subject string
+	var roles []string
+	if claims, ok := auth.ClaimsFromContext(ctx); ok {
+		subject, roles = claims.Subject, claims.Roles
+	}
+	args = append([]any{"audit", true, "operation", operation, "subject", subject, "roles", roles, "reason", reason.Error()}, args...)
+	p.log.WarnContext(ctx, "Authorization denied", args...)
+	return ErrForbidden.Wrap(reason)
+}
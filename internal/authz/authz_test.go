@@ -0,0 +1,51 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/opplieam/dist-mono/internal/apperr"
+	"github.com/opplieam/dist-mono/internal/auth"
+)
+
+func withClaims(subject string, roles ...string) context.Context {
+	claims := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: roles}
+	return auth.WithClaims(context.Background(), claims, "token")
+}
+
+func TestPolicy(t *testing.T) {
+	p := NewPolicy(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name    string
+		check   func() error
+		allowed bool
+	}{
+		{"admin role", func() error { return p.RequireRole(withClaims("1", RoleAdmin), "GetAllUsers", RoleAdmin) }, true},
+		{"missing role", func() error { return p.RequireRole(withClaims("1", "viewer"), "GetAllUsers", RoleAdmin) }, false},
+		{"no claims", func() error { return p.RequireRole(context.Background(), "GetAllUsers", RoleAdmin) }, false},
+		{"owner", func() error { return p.RequireOwner(withClaims("7"), "GetUserById", 7) }, true},
+		{"other user", func() error { return p.RequireOwner(withClaims("7"), "GetUserById", 8) }, false},
+		{"admin on other user", func() error { return p.RequireOwner(withClaims("7", RoleAdmin), "GetUserById", 8) }, true},
+		{"owner of only one", func() error { return p.RequireOwner(withClaims("7"), "UpdateCategory", 7, 8) }, false},
+		{"owner without claims", func() error { return p.RequireOwner(context.Background(), "GetUserById", 7) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check()
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrForbidden) || apperr.KindOf(err) != apperr.KindForbidden {
+				t.Fatalf("error %v is not ErrForbidden", err)
+			}
+		})
+	}
+}
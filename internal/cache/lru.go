@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// LRU is a size-bounded cache where every entry also expires after its TTL. It is safe for
+// concurrent use.
+type LRU[K comparable, V any] struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[K]*list.Element
+	order   *list.List
+
+	hits      metric.Int64Counter
+	misses    metric.Int64Counter
+	evictions metric.Int64Counter
+	attrs     attribute.Set
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// NewLRU creates a cache holding at most size entries. name identifies the cache in the metrics,
+// recorded with meterProvider or the global provider when it is nil.
+func NewLRU[K comparable, V any](name string, size int, meterProvider metric.MeterProvider) (*LRU[K, V], error) {
+	if meterProvider == nil {
+		meterProvider = otel.GetMeterProvider()
+	}
+	meter := meterProvider.Meter("cache")
+	hits, err := meter.Int64Counter("cache.hits", metric.WithDescription("Cache lookups served from the cache"))
+	if err != nil {
+		return nil, err
+	}
+	misses, err := meter.Int64Counter("cache.misses", metric.WithDescription("Cache lookups not found or expired"))
+	if err != nil {
+		return nil, err
+	}
+	evictions, err := meter.Int64Counter("cache.evictions", metric.WithDescription("Entries removed because of the size limit or their TTL"))
+	if err != nil {
+		return nil, err
+	}
+	return &LRU[K, V]{
+		size:      size,
+		now:       time.Now,
+		entries:   make(map[K]*list.Element),
+		order:     list.New(),
+		hits:      hits,
+		misses:    misses,
+		evictions: evictions,
+		attrs:     attribute.NewSet(attribute.String("cache", name)),
+	}, nil
+}
+
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(context.Background(), 1, metric.WithAttributeSet(c.attrs))
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if c.now().After(e.expiresAt) {
+		c.remove(el, "expired")
+		c.misses.Add(context.Background(), 1, metric.WithAttributeSet(c.attrs))
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	c.hits.Add(context.Background(), 1, metric.WithAttributeSet(c.attrs))
+	return e.value, true
+}
+
+func (c *LRU[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expiresAt = value, expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back(), "capacity")
+	}
+}
+
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) remove(el *list.Element, reason string) {
+	e := c.order.Remove(el).(*entry[K, V])
+	delete(c.entries, e.key)
+	c.evictions.Add(context.Background(), 1, metric.WithAttributeSet(c.attrs), metric.WithAttributes(attribute.String("reason", reason)))
+}
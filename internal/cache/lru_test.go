@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// op is a cache operation: a Set of value, a Get expecting a hit on value or a miss, a Delete,
+// or a clock advance by wait.
+type op struct {
+	kind  string
+	key   int
+	value string
+	wait  time.Duration
+}
+
+func set(key int, value string) op { return op{kind: "set", key: key, value: value} }
+func get(key int, want string) op  { return op{kind: "get", key: key, value: want} }
+func miss(key int) op              { return op{kind: "miss", key: key} }
+func del(key int) op               { return op{kind: "delete", key: key} }
+func wait(d time.Duration) op      { return op{kind: "wait", wait: d} }
+
+// counters sums the data points of the int64 counters by name.
+func counters(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range sum.DataPoints {
+					got[m.Name] += dp.Value
+				}
+			}
+		}
+	}
+	return got
+}
+
+func TestLRU(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     []op
+		wantLen int
+		// want holds the hits, misses and evictions.
+		want [3]int64
+	}{
+		{
+			name:    "hit and miss",
+			ops:     []op{set(1, "a"), get(1, "a"), miss(2)},
+			wantLen: 1,
+			want:    [3]int64{1, 1, 0},
+		},
+		{
+			name:    "capacity evicts the least recently used",
+			ops:     []op{set(1, "a"), set(2, "b"), set(3, "c"), miss(1), get(2, "b"), get(3, "c")},
+			wantLen: 2,
+			want:    [3]int64{2, 1, 1},
+		},
+		{
+			name:    "get makes an entry recent",
+			ops:     []op{set(1, "a"), set(2, "b"), get(1, "a"), set(3, "c"), get(1, "a"), miss(2)},
+			wantLen: 2,
+			want:    [3]int64{2, 1, 1},
+		},
+		{
+			name:    "set replaces and makes recent",
+			ops:     []op{set(1, "a"), set(2, "b"), set(1, "z"), set(3, "c"), get(1, "z"), miss(2)},
+			wantLen: 2,
+			want:    [3]int64{1, 1, 1},
+		},
+		{
+			name:    "ttl expiry",
+			ops:     []op{set(1, "a"), wait(time.Minute), get(1, "a"), wait(time.Second), miss(1)},
+			wantLen: 0,
+			want:    [3]int64{1, 1, 1},
+		},
+		{
+			name:    "delete",
+			ops:     []op{set(1, "a"), del(1), miss(1), del(2)},
+			wantLen: 0,
+			want:    [3]int64{0, 1, 0},
+		},
+		{
+			name:    "negative entry",
+			ops:     []op{set(1, ""), get(1, "")},
+			wantLen: 1,
+			want:    [3]int64{1, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := sdkmetric.NewManualReader()
+			c, err := NewLRU[int, string]("test", 2, sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Unix(0, 0)
+			c.now = func() time.Time { return now }
+
+			for i, o := range tt.ops {
+				switch o.kind {
+				case "set":
+					c.Set(o.key, o.value, time.Minute)
+				case "get":
+					if v, ok := c.Get(o.key); !ok || v != o.value {
+						t.Fatalf("op %d: Get(%d) = %q, %v, want a hit on %q", i, o.key, v, ok, o.value)
+					}
+				case "miss":
+					if v, ok := c.Get(o.key); ok {
+						t.Fatalf("op %d: Get(%d) = %q, want a miss", i, o.key, v)
+					}
+				case "delete":
+					c.Delete(o.key)
+				case "wait":
+					now = now.Add(o.wait)
+				}
+			}
+			if got := c.Len(); got != tt.wantLen {
+				t.Errorf("Len = %d, want %d", got, tt.wantLen)
+			}
+			got := counters(t, reader)
+			if have := [3]int64{got["cache.hits"], got["cache.misses"], got["cache.evictions"]}; have != tt.want {
+				t.Errorf("hits, misses, evictions = %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
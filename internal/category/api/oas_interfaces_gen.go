@@ -1,6 +1,30 @@
 // Code generated by ogen, DO NOT EDIT.
 package api
 
+type CreateCategoryRes interface {
+	createCategoryRes()
+}
+
+type DeleteCategoryRes interface {
+	deleteCategoryRes()
+}
+
 type GetCategoryByIdRes interface {
 	getCategoryByIdRes()
 }
+
+type GetUserCategoriesRes interface {
+	getUserCategoriesRes()
+}
+
+type ListCategoriesRes interface {
+	listCategoriesRes()
+}
+
+type PatchCategoryRes interface {
+	patchCategoryRes()
+}
+
+type UpdateCategoryRes interface {
+	updateCategoryRes()
+}
@@ -6,5 +6,11 @@ package api
 type OperationName = string
 
 const (
-	GetCategoryByIdOperation OperationName = "GetCategoryById"
+	CreateCategoryOperation    OperationName = "CreateCategory"
+	DeleteCategoryOperation    OperationName = "DeleteCategory"
+	GetCategoryByIdOperation   OperationName = "GetCategoryById"
+	GetUserCategoriesOperation OperationName = "GetUserCategories"
+	ListCategoriesOperation    OperationName = "ListCategories"
+	PatchCategoryOperation     OperationName = "PatchCategory"
+	UpdateCategoryOperation    OperationName = "UpdateCategory"
 )
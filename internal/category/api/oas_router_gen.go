@@ -49,31 +49,129 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		switch elem[0] {
-		case '/': // Prefix: "/category/"
+		case '/': // Prefix: "/"
 			origElem := elem
-			if l := len("/category/"); len(elem) >= l && elem[0:l] == "/category/" {
+			if l := len("/"); len(elem) >= l && elem[0:l] == "/" {
 				elem = elem[l:]
 			} else {
 				break
 			}
 
-			// Param: "id"
-			// Leaf parameter
-			args[0] = elem
-			elem = ""
-
 			if len(elem) == 0 {
-				// Leaf node.
-				switch r.Method {
-				case "GET":
-					s.handleGetCategoryByIdRequest([1]string{
-						args[0],
-					}, elemIsEscaped, w, r)
-				default:
-					s.notAllowed(w, r, "GET")
+				break
+			}
+			switch elem[0] {
+			case 'c': // Prefix: "category"
+				origElem := elem
+				if l := len("category"); len(elem) >= l && elem[0:l] == "category" {
+					elem = elem[l:]
+				} else {
+					break
+				}
+
+				if len(elem) == 0 {
+					switch r.Method {
+					case "GET":
+						s.handleListCategoriesRequest([0]string{}, elemIsEscaped, w, r)
+					case "POST":
+						s.handleCreateCategoryRequest([0]string{}, elemIsEscaped, w, r)
+					default:
+						s.notAllowed(w, r, "GET,POST")
+					}
+
+					return
+				}
+				switch elem[0] {
+				case '/': // Prefix: "/"
+					origElem := elem
+					if l := len("/"); len(elem) >= l && elem[0:l] == "/" {
+						elem = elem[l:]
+					} else {
+						break
+					}
+
+					// Param: "id"
+					// Leaf parameter
+					args[0] = elem
+					elem = ""
+
+					if len(elem) == 0 {
+						// Leaf node.
+						switch r.Method {
+						case "DELETE":
+							s.handleDeleteCategoryRequest([1]string{
+								args[0],
+							}, elemIsEscaped, w, r)
+						case "GET":
+							s.handleGetCategoryByIdRequest([1]string{
+								args[0],
+							}, elemIsEscaped, w, r)
+						case "PATCH":
+							s.handlePatchCategoryRequest([1]string{
+								args[0],
+							}, elemIsEscaped, w, r)
+						case "PUT":
+							s.handleUpdateCategoryRequest([1]string{
+								args[0],
+							}, elemIsEscaped, w, r)
+						default:
+							s.notAllowed(w, r, "DELETE,GET,PATCH,PUT")
+						}
+
+						return
+					}
+
+					elem = origElem
+				}
+
+				elem = origElem
+			case 'u': // Prefix: "user/"
+				origElem := elem
+				if l := len("user/"); len(elem) >= l && elem[0:l] == "user/" {
+					elem = elem[l:]
+				} else {
+					break
+				}
+
+				// Param: "userId"
+				// Match until "/"
+				idx := strings.IndexByte(elem, '/')
+				if idx < 0 {
+					idx = len(elem)
+				}
+				args[0] = elem[:idx]
+				elem = elem[idx:]
+
+				if len(elem) == 0 {
+					break
+				}
+				switch elem[0] {
+				case '/': // Prefix: "/category"
+					origElem := elem
+					if l := len("/category"); len(elem) >= l && elem[0:l] == "/category" {
+						elem = elem[l:]
+					} else {
+						break
+					}
+
+					if len(elem) == 0 {
+						// Leaf node.
+						switch r.Method {
+						case "GET":
+							s.handleGetUserCategoriesRequest([1]string{
+								args[0],
+							}, elemIsEscaped, w, r)
+						default:
+							s.notAllowed(w, r, "GET")
+						}
+
+						return
+					}
+
+					elem = origElem
 				}
 
-				return
+				elem = origElem
 			}
 
 			elem = origElem
@@ -157,33 +255,155 @@ func (s *Server) FindPath(method string, u *url.URL) (r Route, _ bool) {
 			break
 		}
 		switch elem[0] {
-		case '/': // Prefix: "/category/"
+		case '/': // Prefix: "/"
 			origElem := elem
-			if l := len("/category/"); len(elem) >= l && elem[0:l] == "/category/" {
+			if l := len("/"); len(elem) >= l && elem[0:l] == "/" {
 				elem = elem[l:]
 			} else {
 				break
 			}
 
-			// Param: "id"
-			// Leaf parameter
-			args[0] = elem
-			elem = ""
-
 			if len(elem) == 0 {
-				// Leaf node.
-				switch method {
-				case "GET":
-					r.name = GetCategoryByIdOperation
-					r.summary = "Get a category by ID"
-					r.operationID = "getCategoryById"
-					r.pathPattern = "/category/{id}"
-					r.args = args
-					r.count = 1
-					return r, true
-				default:
-					return
+				break
+			}
+			switch elem[0] {
+			case 'c': // Prefix: "category"
+				origElem := elem
+				if l := len("category"); len(elem) >= l && elem[0:l] == "category" {
+					elem = elem[l:]
+				} else {
+					break
+				}
+
+				if len(elem) == 0 {
+					switch method {
+					case "GET":
+						r.name = ListCategoriesOperation
+						r.summary = "Get all categories"
+						r.operationID = "listCategories"
+						r.pathPattern = "/category"
+						r.args = args
+						r.count = 0
+						return r, true
+					case "POST":
+						r.name = CreateCategoryOperation
+						r.summary = "Create a new category"
+						r.operationID = "createCategory"
+						r.pathPattern = "/category"
+						r.args = args
+						r.count = 0
+						return r, true
+					default:
+						return
+					}
+				}
+				switch elem[0] {
+				case '/': // Prefix: "/"
+					origElem := elem
+					if l := len("/"); len(elem) >= l && elem[0:l] == "/" {
+						elem = elem[l:]
+					} else {
+						break
+					}
+
+					// Param: "id"
+					// Leaf parameter
+					args[0] = elem
+					elem = ""
+
+					if len(elem) == 0 {
+						// Leaf node.
+						switch method {
+						case "DELETE":
+							r.name = DeleteCategoryOperation
+							r.summary = "Delete a category by ID"
+							r.operationID = "deleteCategory"
+							r.pathPattern = "/category/{id}"
+							r.args = args
+							r.count = 1
+							return r, true
+						case "GET":
+							r.name = GetCategoryByIdOperation
+							r.summary = "Get a category by ID"
+							r.operationID = "getCategoryById"
+							r.pathPattern = "/category/{id}"
+							r.args = args
+							r.count = 1
+							return r, true
+						case "PATCH":
+							r.name = PatchCategoryOperation
+							r.summary = "Partially update a category by ID"
+							r.operationID = "patchCategory"
+							r.pathPattern = "/category/{id}"
+							r.args = args
+							r.count = 1
+							return r, true
+						case "PUT":
+							r.name = UpdateCategoryOperation
+							r.summary = "Replace a category by ID"
+							r.operationID = "updateCategory"
+							r.pathPattern = "/category/{id}"
+							r.args = args
+							r.count = 1
+							return r, true
+						default:
+							return
+						}
+					}
+
+					elem = origElem
 				}
+
+				elem = origElem
+			case 'u': // Prefix: "user/"
+				origElem := elem
+				if l := len("user/"); len(elem) >= l && elem[0:l] == "user/" {
+					elem = elem[l:]
+				} else {
+					break
+				}
+
+				// Param: "userId"
+				// Match until "/"
+				idx := strings.IndexByte(elem, '/')
+				if idx < 0 {
+					idx = len(elem)
+				}
+				args[0] = elem[:idx]
+				elem = elem[idx:]
+
+				if len(elem) == 0 {
+					break
+				}
+				switch elem[0] {
+				case '/': // Prefix: "/category"
+					origElem := elem
+					if l := len("/category"); len(elem) >= l && elem[0:l] == "/category" {
+						elem = elem[l:]
+					} else {
+						break
+					}
+
+					if len(elem) == 0 {
+						// Leaf node.
+						switch method {
+						case "GET":
+							r.name = GetUserCategoriesOperation
+							r.summary = "Get all categories of a user"
+							r.operationID = "getUserCategories"
+							r.pathPattern = "/user/{userId}/category"
+							r.args = args
+							r.count = 1
+							return r, true
+						default:
+							return
+						}
+					}
+
+					elem = origElem
+				}
+
+				elem = origElem
 			}
 
 			elem = origElem
@@ -10,12 +10,28 @@ func (s *ErrorStatusCode) Error() string {
 	return fmt.Sprintf("code %d: %+v", s.StatusCode, s.Response)
 }
 
+type BearerAuth struct {
+	Token string
+}
+
+// GetToken returns the value of Token.
+func (s *BearerAuth) GetToken() string {
+	return s.Token
+}
+
+// SetToken sets the value of Token.
+func (s *BearerAuth) SetToken(val string) {
+	s.Token = val
+}
+
 // Ref: #/components/schemas/Category
 type Category struct {
 	// The unique identifier for the category.
 	ID int `json:"id"`
 	// The name of the category.
 	Name string `json:"name"`
+	// The unique identifier of the user owning the category.
+	UserID int `json:"user_id"`
 }
 
 // GetID returns the value of ID.
@@ -28,6 +44,11 @@ func (s *Category) GetName() string {
 	return s.Name
 }
 
+// GetUserID returns the value of UserID.
+func (s *Category) GetUserID() int {
+	return s.UserID
+}
+
 // SetID sets the value of ID.
 func (s *Category) SetID(val int) {
 	s.ID = val
@@ -38,7 +59,92 @@ func (s *Category) SetName(val string) {
 	s.Name = val
 }
 
+// SetUserID sets the value of UserID.
+func (s *Category) SetUserID(val int) {
+	s.UserID = val
+}
+
+func (*Category) createCategoryRes()  {}
 func (*Category) getCategoryByIdRes() {}
+func (*Category) patchCategoryRes()   {}
+func (*Category) updateCategoryRes()  {}
+
+// Ref: #/components/schemas/CategoryInput
+type CategoryInput struct {
+	// The name of the category.
+	Name string `json:"name"`
+	// The unique identifier of the user owning the category.
+	UserID int `json:"user_id"`
+}
+
+// GetName returns the value of Name.
+func (s *CategoryInput) GetName() string {
+	return s.Name
+}
+
+// GetUserID returns the value of UserID.
+func (s *CategoryInput) GetUserID() int {
+	return s.UserID
+}
+
+// SetName sets the value of Name.
+func (s *CategoryInput) SetName(val string) {
+	s.Name = val
+}
+
+// SetUserID sets the value of UserID.
+func (s *CategoryInput) SetUserID(val int) {
+	s.UserID = val
+}
+
+// Ref: #/components/schemas/CategoryPatch
+type CategoryPatch struct {
+	// The name of the category.
+	Name OptString `json:"name"`
+	// The unique identifier of the user owning the category.
+	UserID OptInt `json:"user_id"`
+}
+
+// GetName returns the value of Name.
+func (s *CategoryPatch) GetName() OptString {
+	return s.Name
+}
+
+// GetUserID returns the value of UserID.
+func (s *CategoryPatch) GetUserID() OptInt {
+	return s.UserID
+}
+
+// SetName sets the value of Name.
+func (s *CategoryPatch) SetName(val OptString) {
+	s.Name = val
+}
+
+// SetUserID sets the value of UserID.
+func (s *CategoryPatch) SetUserID(val OptInt) {
+	s.UserID = val
+}
+
+type CreateCategoryBadRequest Error
+
+func (*CreateCategoryBadRequest) createCategoryRes() {}
+
+type CreateCategoryInternalServerError Error
+
+func (*CreateCategoryInternalServerError) createCategoryRes() {}
+
+type DeleteCategoryBadRequest Error
+
+func (*DeleteCategoryBadRequest) deleteCategoryRes() {}
+
+type DeleteCategoryInternalServerError Error
+
+func (*DeleteCategoryInternalServerError) deleteCategoryRes() {}
+
+// DeleteCategoryNoContent is response for DeleteCategory operation.
+type DeleteCategoryNoContent struct{}
+
+func (*DeleteCategoryNoContent) deleteCategoryRes() {}
 
 // Ref: #/components/schemas/Error
 type Error struct {
@@ -89,3 +195,135 @@ func (*GetCategoryByIdBadRequest) getCategoryByIdRes() {}
 type GetCategoryByIdInternalServerError Error
 
 func (*GetCategoryByIdInternalServerError) getCategoryByIdRes() {}
+
+type GetUserCategoriesBadRequest Error
+
+func (*GetUserCategoriesBadRequest) getUserCategoriesRes() {}
+
+type GetUserCategoriesInternalServerError Error
+
+func (*GetUserCategoriesInternalServerError) getUserCategoriesRes() {}
+
+type GetUserCategoriesOKApplicationJSON []Category
+
+func (*GetUserCategoriesOKApplicationJSON) getUserCategoriesRes() {}
+
+type ListCategoriesBadRequest Error
+
+func (*ListCategoriesBadRequest) listCategoriesRes() {}
+
+type ListCategoriesInternalServerError Error
+
+func (*ListCategoriesInternalServerError) listCategoriesRes() {}
+
+type ListCategoriesOKApplicationJSON []Category
+
+func (*ListCategoriesOKApplicationJSON) listCategoriesRes() {}
+
+// NewOptInt returns new OptInt with value set to v.
+func NewOptInt(v int) OptInt {
+	return OptInt{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptInt is optional int.
+type OptInt struct {
+	Value int
+	Set   bool
+}
+
+// IsSet returns true if OptInt was set.
+func (o OptInt) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptInt) Reset() {
+	var v int
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptInt) SetTo(v int) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptInt) Get() (v int, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptInt) Or(d int) int {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
+// NewOptString returns new OptString with value set to v.
+func NewOptString(v string) OptString {
+	return OptString{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptString is optional string.
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+// IsSet returns true if OptString was set.
+func (o OptString) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptString) Reset() {
+	var v string
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptString) SetTo(v string) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptString) Get() (v string, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptString) Or(d string) string {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
+type PatchCategoryBadRequest Error
+
+func (*PatchCategoryBadRequest) patchCategoryRes() {}
+
+type PatchCategoryInternalServerError Error
+
+func (*PatchCategoryInternalServerError) patchCategoryRes() {}
+
+type UpdateCategoryBadRequest Error
+
+func (*UpdateCategoryBadRequest) updateCategoryRes() {}
+
+type UpdateCategoryInternalServerError Error
+
+func (*UpdateCategoryInternalServerError) updateCategoryRes() {}
@@ -8,12 +8,48 @@ import (
 
 // Handler handles operations described by OpenAPI v3 specification.
 type Handler interface {
+	// CreateCategory implements createCategory operation.
+	//
+	// Create a new category.
+	//
+	// POST /category
+	CreateCategory(ctx context.Context, req *CategoryInput) (CreateCategoryRes, error)
+	// DeleteCategory implements deleteCategory operation.
+	//
+	// Delete a category by ID.
+	//
+	// DELETE /category/{id}
+	DeleteCategory(ctx context.Context, params DeleteCategoryParams) (DeleteCategoryRes, error)
 	// GetCategoryById implements getCategoryById operation.
 	//
 	// Get a category by ID.
 	//
 	// GET /category/{id}
 	GetCategoryById(ctx context.Context, params GetCategoryByIdParams) (GetCategoryByIdRes, error)
+	// GetUserCategories implements getUserCategories operation.
+	//
+	// Get all categories of a user.
+	//
+	// GET /user/{userId}/category
+	GetUserCategories(ctx context.Context, params GetUserCategoriesParams) (GetUserCategoriesRes, error)
+	// ListCategories implements listCategories operation.
+	//
+	// Get all categories.
+	//
+	// GET /category
+	ListCategories(ctx context.Context) (ListCategoriesRes, error)
+	// PatchCategory implements patchCategory operation.
+	//
+	// Partially update a category by ID.
+	//
+	// PATCH /category/{id}
+	PatchCategory(ctx context.Context, req *CategoryPatch, params PatchCategoryParams) (PatchCategoryRes, error)
+	// UpdateCategory implements updateCategory operation.
+	//
+	// Replace a category by ID.
+	//
+	// PUT /category/{id}
+	UpdateCategory(ctx context.Context, req *CategoryInput, params UpdateCategoryParams) (UpdateCategoryRes, error)
 	// NewError creates *ErrorStatusCode from error returned by handler.
 	//
 	// Used for common default response.
@@ -23,18 +59,20 @@ type Handler interface {
 // Server implements http server based on OpenAPI v3 specification and
 // calls Handler to handle requests.
 type Server struct {
-	h Handler
+	h   Handler
+	sec SecurityHandler
 	baseServer
 }
 
 // NewServer creates new Server.
-func NewServer(h Handler, opts ...ServerOption) (*Server, error) {
+func NewServer(h Handler, sec SecurityHandler, opts ...ServerOption) (*Server, error) {
 	s, err := newServerConfig(opts...).baseServer()
 	if err != nil {
 		return nil, err
 	}
 	return &Server{
 		h:          h,
+		sec:        sec,
 		baseServer: s,
 	}, nil
 }
@@ -13,6 +13,24 @@ type UnimplementedHandler struct{}
 
 var _ Handler = UnimplementedHandler{}
 
+// CreateCategory implements createCategory operation.
+//
+// Create a new category.
+//
+// POST /category
+func (UnimplementedHandler) CreateCategory(ctx context.Context, req *CategoryInput) (r CreateCategoryRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// DeleteCategory implements deleteCategory operation.
+//
+// Delete a category by ID.
+//
+// DELETE /category/{id}
+func (UnimplementedHandler) DeleteCategory(ctx context.Context, params DeleteCategoryParams) (r DeleteCategoryRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
 // GetCategoryById implements getCategoryById operation.
 //
 // Get a category by ID.
@@ -22,6 +40,42 @@ func (UnimplementedHandler) GetCategoryById(ctx context.Context, params GetCateg
 	return r, ht.ErrNotImplemented
 }
 
+// GetUserCategories implements getUserCategories operation.
+//
+// Get all categories of a user.
+//
+// GET /user/{userId}/category
+func (UnimplementedHandler) GetUserCategories(ctx context.Context, params GetUserCategoriesParams) (r GetUserCategoriesRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// ListCategories implements listCategories operation.
+//
+// Get all categories.
+//
+// GET /category
+func (UnimplementedHandler) ListCategories(ctx context.Context) (r ListCategoriesRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// PatchCategory implements patchCategory operation.
+//
+// Partially update a category by ID.
+//
+// PATCH /category/{id}
+func (UnimplementedHandler) PatchCategory(ctx context.Context, req *CategoryPatch, params PatchCategoryParams) (r PatchCategoryRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// UpdateCategory implements updateCategory operation.
+//
+// Replace a category by ID.
+//
+// PUT /category/{id}
+func (UnimplementedHandler) UpdateCategory(ctx context.Context, req *CategoryInput, params UpdateCategoryParams) (r UpdateCategoryRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
 // NewError creates *ErrorStatusCode from error returned by handler.
 //
 // Used for common default response.
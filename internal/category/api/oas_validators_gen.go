@@ -0,0 +1,23 @@
+// Code generated by ogen, DO NOT EDIT.
+
+package api
+
+import (
+	"github.com/go-faster/errors"
+)
+
+func (s GetUserCategoriesOKApplicationJSON) Validate() error {
+	alias := ([]Category)(s)
+	if alias == nil {
+		return errors.New("nil is invalid value")
+	}
+	return nil
+}
+
+func (s ListCategoriesOKApplicationJSON) Validate() error {
+	alias := ([]Category)(s)
+	if alias == nil {
+		return errors.New("nil is invalid value")
+	}
+	return nil
+}
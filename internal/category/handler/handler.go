@@ -2,100 +2,385 @@ package handler
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ogen-go/ogen/ogenerrors"
+	"github.com/opplieam/dist-mono/internal/apperr"
+	"github.com/opplieam/dist-mono/internal/auth"
+	"github.com/opplieam/dist-mono/internal/authz"
 	"github.com/opplieam/dist-mono/internal/category/api"
 	"github.com/opplieam/dist-mono/internal/category/store"
+	"github.com/opplieam/dist-mono/internal/health"
+	"github.com/opplieam/dist-mono/internal/logger"
+	"github.com/opplieam/dist-mono/internal/ratelimit"
+	"github.com/opplieam/dist-mono/internal/telemetry"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Storer interface {
-	GetCategoryByID(ctx context.Context, userID int) (*store.CategoryResult, error)
+	GetCategoryByID(ctx context.Context, id int) (*store.CategoryResult, error)
+	ListCategories(ctx context.Context) ([]store.CategoryResult, error)
+	ListCategoriesByUserID(ctx context.Context, userID int) ([]store.CategoryResult, error)
+	CreateCategory(ctx context.Context, name string, userID int) (*store.CategoryResult, error)
+	UpdateCategory(ctx context.Context, id int, name string, userID int) (*store.CategoryResult, error)
+	PatchCategory(ctx context.Context, id int, patch store.CategoryPatch) (*store.CategoryResult, error)
+	DeleteCategory(ctx context.Context, id int) error
+}
+
+type Config struct {
+	Addr string
+	// HealthCheckTimeout bounds each readiness check registered without its own timeout.
+	HealthCheckTimeout time.Duration
+	// TLS serves HTTPS when set.
+	TLS *tls.Config
+	// RateLimiter limits the API requests of each client, nil disables rate limiting.
+	RateLimiter *ratelimit.Limiter
+	// MeterProvider and TracerProvider record the telemetry of the service, the global providers
+	// are used when they are nil.
+	MeterProvider  metric.MeterProvider
+	TracerProvider trace.TracerProvider
+	// Callers restricts the API to the allowlisted services, nil lets any caller through.
+	Callers *auth.CallerPolicy
+	// OnUserCategoriesChanged is called with each user whose categories a write changed, so
+	// their cached copies can be dropped. It is optional.
+	OnUserCategoriesChanged func(userID int)
 }
 
 type CategoryHandler struct {
-	hServer *http.Server
-	store   Storer
+	cfg      Config
+	hServer  *http.Server
+	listener net.Listener
+	store    Storer
+	verifier *auth.Verifier
+	policy   *authz.Policy
+	health   *health.Registry
+	log      *slog.Logger
+
+	handlerOnce sync.Once
+	handler     http.Handler
+	handlerErr  error
 }
 
-var _ api.Handler = (*CategoryHandler)(nil)
+var (
+	_ api.Handler         = (*CategoryHandler)(nil)
+	_ api.SecurityHandler = (*CategoryHandler)(nil)
+)
 
-func NewCategoryHandler(s Storer) *CategoryHandler {
+func NewCategoryHandler(s Storer, verifier *auth.Verifier, log *slog.Logger, cfg Config) *CategoryHandler {
 	return &CategoryHandler{
-		store: s,
+		cfg:      cfg,
+		store:    s,
+		verifier: verifier,
+		policy:   authz.NewPolicy(log),
+		health:   health.NewRegistry(cfg.HealthCheckTimeout),
+		log:      log,
 	}
 }
 
-func (h *CategoryHandler) Start() (chan os.Signal, error) {
-	srv, err := api.NewServer(h)
+// Health returns the registry behind the probe endpoints, checks must be registered before Start.
+func (h *CategoryHandler) Health() *health.Registry {
+	return h.health
+}
+
+// Handler returns the HTTP handler of the service, the API under /v1 and the probes. It is built
+// once, so it can also be called in-process.
+func (h *CategoryHandler) Handler() (http.Handler, error) {
+	h.handlerOnce.Do(func() {
+		srv, err := api.NewServer(h, h,
+			api.WithErrorHandler(h.handleError),
+			api.WithMeterProvider(h.cfg.MeterProvider),
+			api.WithTracerProvider(h.cfg.TracerProvider),
+			api.WithMiddleware(logger.OgenMiddleware("userId")),
+		)
+		if err != nil {
+			h.handlerErr = fmt.Errorf("failed to create server: %w", err)
+			return
+		}
+		r := chi.NewRouter()
+		r.Use(middleware.RequestID)
+		r.Use(telemetry.ExtractTraceContext)
+		r.Use(logger.RequestLogger(h.log))
+		r.Use(middleware.Recoverer)
+
+		h.health.Mount(r)
+		r.Group(func(r chi.Router) {
+			if h.cfg.Callers != nil {
+				r.Use(h.requireCaller)
+			}
+			r.Mount("/v1", http.StripPrefix("/v1", h.rateLimit(srv)))
+		})
+		h.handler = r
+	})
+	return h.handler, h.handlerErr
+}
+
+// Start binds the listen address, so a bad address fails the startup instead of a background
+// goroutine. Requests are only served once Serve is called.
+func (h *CategoryHandler) Start() error {
+	handler, err := h.Handler()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create server: %w", err)
+		return err
 	}
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-
-	r.Mount("/v1", http.StripPrefix("/v1", srv))
 	h.hServer = &http.Server{
-		Addr:    ":4000",
-		Handler: r,
+		Addr:    h.cfg.Addr,
+		Handler: handler,
 	}
 
-	go func() {
-		log.Printf("Category service listening on %s", h.hServer.Addr)
-		if hErr := h.hServer.ListenAndServe(); !errors.Is(hErr, http.ErrServerClosed) {
-			log.Fatalf("Failed to listen on %s: %v", h.hServer.Addr, hErr)
-		}
-	}()
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	h.listener, err = net.Listen("tcp", h.hServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", h.hServer.Addr, err)
+	}
+	if h.cfg.TLS != nil {
+		h.listener = tls.NewListener(h.listener, h.cfg.TLS)
+	}
+	h.health.MarkStarted()
+	return nil
+}
 
-	return sigChan, nil
+// Serve serves requests until Shutdown, it returns nil once the server is shut down.
+func (h *CategoryHandler) Serve() error {
+	h.log.Info("Category service listening", "addr", h.listener.Addr().String(), "tls", h.cfg.TLS != nil)
+	if err := h.hServer.Serve(h.listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
-func (h *CategoryHandler) Shutdown() error {
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Drain fails the readiness probe, so load balancers stop routing new requests here.
+func (h *CategoryHandler) Drain() {
+	h.health.MarkShuttingDown()
+}
 
-	return h.hServer.Shutdown(shutdownCtx)
+// Shutdown stops accepting connections and waits for in-flight requests until ctx is done.
+func (h *CategoryHandler) Shutdown(ctx context.Context) error {
+	h.health.MarkShuttingDown()
+	return h.hServer.Shutdown(ctx)
 }
 
 func (h *CategoryHandler) GetCategoryById(ctx context.Context, params api.GetCategoryByIdParams) (api.GetCategoryByIdRes, error) {
-	log.Printf("GetCategoryById: %v", params)
-	res, err := h.store.GetCategoryByID(ctx, params.ID)
+	h.log.DebugContext(ctx, "GetCategoryById", "id", params.ID)
+	res, err := h.ownedCategory(ctx, api.GetCategoryByIdOperation, params.ID)
 	if err != nil {
 		return nil, err
 	}
-	return &api.Category{
-		ID:   res.ID,
-		Name: res.Name,
-	}, nil
+	return toApiCategory(res), nil
 }
 
-func (h *CategoryHandler) NewError(ctx context.Context, err error) *api.ErrorStatusCode {
-	switch {
-	case errors.Is(err, store.ErrCategoryNotFound):
-		return &api.ErrorStatusCode{
-			StatusCode: http.StatusNotFound,
-			Response: api.Error{
-				Message: err.Error(),
-			},
+func (h *CategoryHandler) ListCategories(ctx context.Context) (api.ListCategoriesRes, error) {
+	if err := h.policy.RequireRole(ctx, string(api.ListCategoriesOperation), authz.RoleAdmin); err != nil {
+		return nil, err
+	}
+	res, err := h.store.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	categories := make(api.ListCategoriesOKApplicationJSON, 0, len(res))
+	for i := range res {
+		categories = append(categories, *toApiCategory(&res[i]))
+	}
+	return &categories, nil
+}
+
+func (h *CategoryHandler) GetUserCategories(ctx context.Context, params api.GetUserCategoriesParams) (api.GetUserCategoriesRes, error) {
+	if err := h.policy.RequireOwner(ctx, string(api.GetUserCategoriesOperation), params.UserId); err != nil {
+		return nil, err
+	}
+	res, err := h.store.ListCategoriesByUserID(ctx, params.UserId)
+	if err != nil {
+		return nil, err
+	}
+	categories := make(api.GetUserCategoriesOKApplicationJSON, 0, len(res))
+	for i := range res {
+		categories = append(categories, *toApiCategory(&res[i]))
+	}
+	return &categories, nil
+}
+
+func (h *CategoryHandler) CreateCategory(ctx context.Context, req *api.CategoryInput) (api.CreateCategoryRes, error) {
+	if err := h.policy.RequireOwner(ctx, string(api.CreateCategoryOperation), req.GetUserID()); err != nil {
+		return nil, err
+	}
+	res, err := h.store.CreateCategory(ctx, req.GetName(), req.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+	h.userCategoriesChanged(res.UserID)
+	return toApiCategory(res), nil
+}
+
+func (h *CategoryHandler) UpdateCategory(ctx context.Context, req *api.CategoryInput, params api.UpdateCategoryParams) (api.UpdateCategoryRes, error) {
+	old, err := h.authorizeCategory(ctx, api.UpdateCategoryOperation, params.ID, req.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+	res, err := h.store.UpdateCategory(ctx, params.ID, req.GetName(), req.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+	h.userCategoriesChanged(old.UserID, res.UserID)
+	return toApiCategory(res), nil
+}
+
+func (h *CategoryHandler) PatchCategory(ctx context.Context, req *api.CategoryPatch, params api.PatchCategoryParams) (api.PatchCategoryRes, error) {
+	var patch store.CategoryPatch
+	var newOwners []int
+	if name, ok := req.GetName().Get(); ok {
+		patch.Name = &name
+	}
+	if userID, ok := req.GetUserID().Get(); ok {
+		patch.UserID = &userID
+		newOwners = append(newOwners, userID)
+	}
+	old, err := h.authorizeCategory(ctx, api.PatchCategoryOperation, params.ID, newOwners...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := h.store.PatchCategory(ctx, params.ID, patch)
+	if err != nil {
+		return nil, err
+	}
+	h.userCategoriesChanged(old.UserID, res.UserID)
+	return toApiCategory(res), nil
+}
+
+func (h *CategoryHandler) DeleteCategory(ctx context.Context, params api.DeleteCategoryParams) (api.DeleteCategoryRes, error) {
+	old, err := h.authorizeCategory(ctx, api.DeleteCategoryOperation, params.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.store.DeleteCategory(ctx, params.ID); err != nil {
+		return nil, err
+	}
+	h.userCategoriesChanged(old.UserID)
+	return &api.DeleteCategoryNoContent{}, nil
+}
+
+// authorizeCategory returns category id when the caller may change it: the admins, and its owner
+// when every one of newOwners is them too, so a category can't be handed over to another user.
+func (h *CategoryHandler) authorizeCategory(ctx context.Context, op api.OperationName, id int, newOwners ...int) (*store.CategoryResult, error) {
+	res, err := h.ownedCategory(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.policy.RequireOwner(ctx, string(op), newOwners...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ownedCategory returns category id to the admins and its owner. The other users get
+// authz.ErrForbidden, like for the users of the user service.
+func (h *CategoryHandler) ownedCategory(ctx context.Context, op api.OperationName, id int) (*store.CategoryResult, error) {
+	res, err := h.store.GetCategoryByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.policy.RequireOwner(ctx, string(op), res.UserID); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (h *CategoryHandler) userCategoriesChanged(userIDs ...int) {
+	if h.cfg.OnUserCategoriesChanged == nil {
+		return
+	}
+	for i, id := range userIDs {
+		if i == 0 || id != userIDs[0] {
+			h.cfg.OnUserCategoriesChanged(id)
 		}
-	default:
-		return &api.ErrorStatusCode{
-			StatusCode: http.StatusInternalServerError,
-			Response: api.Error{
-				Message: err.Error(),
-			},
+	}
+}
+
+func toApiCategory(c *store.CategoryResult) *api.Category {
+	return &api.Category{
+		ID:     c.ID,
+		Name:   c.Name,
+		UserID: c.UserID,
+	}
+}
+
+// HandleBearerAuth verifies the bearer token, puts its claims in the context and records the
+// user for the logs.
+func (h *CategoryHandler) HandleBearerAuth(ctx context.Context, _ api.OperationName, t api.BearerAuth) (context.Context, error) {
+	claims, err := h.verifier.VerifyContext(ctx, t.Token)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetUserID(ctx, claims.Subject)
+	return auth.WithClaims(ctx, claims, t.Token), nil
+}
+
+// requireCaller rejects the requests of the services missing from the caller allowlist.
+func (h *CategoryHandler) requireCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, err := h.cfg.Callers.Caller(r)
+		if err != nil {
+			h.handleError(r.Context(), w, r, err)
+			return
 		}
+		next.ServeHTTP(w, r.WithContext(auth.WithCaller(r.Context(), caller)))
+	})
+}
+
+// rateLimit limits the requests served by srv per client and operation when a rate limiter is
+// configured. The bearer token is verified before, to identify the client, and HandleBearerAuth
+// reuses the outcome.
+func (h *CategoryHandler) rateLimit(srv *api.Server) http.Handler {
+	if h.cfg.RateLimiter == nil {
+		return srv
+	}
+	operation := func(r *http.Request) string {
+		route, _ := srv.FindPath(r.Method, r.URL)
+		return route.Name()
+	}
+	reject := func(w http.ResponseWriter, r *http.Request, err error) {
+		h.handleError(r.Context(), w, r, err)
+	}
+	return h.verifier.Authenticate(h.cfg.RateLimiter.Middleware(operation, reject)(srv))
+}
+
+// handleError reports the request decoding errors through NewError, so every error response
+// has the same shape.
+func (h *CategoryHandler) handleError(ctx context.Context, w http.ResponseWriter, _ *http.Request, err error) {
+	if code := ogenerrors.ErrorCode(err); code >= http.StatusBadRequest && code < http.StatusInternalServerError {
+		err = &apperr.Error{Kind: apperr.KindInvalid, Message: err.Error(), Err: err}
+	}
+	res := h.NewError(ctx, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(res.StatusCode)
+	_ = json.NewEncoder(w).Encode(res.Response)
+}
+
+func (h *CategoryHandler) NewError(ctx context.Context, err error) *api.ErrorStatusCode {
+	var secErr *ogenerrors.SecurityError
+	if errors.As(err, &secErr) && apperr.KindOf(err) == apperr.KindInternal {
+		// No or malformed Authorization header.
+		err = auth.ErrUnauthenticated.Wrap(err)
+	}
+	appErr := apperr.Translate(err)
+	// Only the public message goes to the client, the full error stays in logs and spans.
+	trace.SpanFromContext(ctx).RecordError(err)
+	level := slog.LevelWarn
+	if appErr.Kind == apperr.KindInternal {
+		level = slog.LevelError
+	}
+	h.log.Log(ctx, level, "Request failed", "error", err, "type", appErr.Kind.String())
+	return &api.ErrorStatusCode{
+		StatusCode: appErr.Kind.StatusCode(),
+		Response: api.Error{
+			Message: appErr.Message,
+		},
 	}
 }
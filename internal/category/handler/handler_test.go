@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/opplieam/dist-mono/internal/auth"
+	"github.com/opplieam/dist-mono/internal/authz"
+	"github.com/opplieam/dist-mono/internal/category/api"
+	"github.com/opplieam/dist-mono/internal/category/store"
+)
+
+// memStore is a Storer keeping the categories in memory.
+type memStore struct {
+	Storer
+	categories map[int]store.CategoryResult
+}
+
+func newMemStore(categories ...store.CategoryResult) *memStore {
+	s := &memStore{categories: make(map[int]store.CategoryResult)}
+	for _, c := range categories {
+		s.categories[c.ID] = c
+	}
+	return s
+}
+
+func (s *memStore) GetCategoryByID(_ context.Context, id int) (*store.CategoryResult, error) {
+	c, ok := s.categories[id]
+	if !ok {
+		return nil, store.ErrCategoryNotFound
+	}
+	return &c, nil
+}
+
+func (s *memStore) CreateCategory(_ context.Context, name string, userID int) (*store.CategoryResult, error) {
+	c := store.CategoryResult{ID: len(s.categories) + 1, Name: name, UserID: userID}
+	s.categories[c.ID] = c
+	return &c, nil
+}
+
+func (s *memStore) UpdateCategory(_ context.Context, id int, name string, userID int) (*store.CategoryResult, error) {
+	if _, ok := s.categories[id]; !ok {
+		return nil, store.ErrCategoryNotFound
+	}
+	c := store.CategoryResult{ID: id, Name: name, UserID: userID}
+	s.categories[id] = c
+	return &c, nil
+}
+
+func (s *memStore) PatchCategory(_ context.Context, id int, patch store.CategoryPatch) (*store.CategoryResult, error) {
+	c, ok := s.categories[id]
+	if !ok {
+		return nil, store.ErrCategoryNotFound
+	}
+	if patch.Name != nil {
+		c.Name = *patch.Name
+	}
+	if patch.UserID != nil {
+		c.UserID = *patch.UserID
+	}
+	s.categories[id] = c
+	return &c, nil
+}
+
+func (s *memStore) DeleteCategory(_ context.Context, id int) error {
+	if _, ok := s.categories[id]; !ok {
+		return store.ErrCategoryNotFound
+	}
+	delete(s.categories, id)
+	return nil
+}
+
+func withClaims(subject string, roles ...string) context.Context {
+	claims := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: roles}
+	return auth.WithClaims(context.Background(), claims, "token")
+}
+
+func newTestHandler(s Storer, cfg Config) *CategoryHandler {
+	return NewCategoryHandler(s, nil, slog.New(slog.NewTextHandler(io.Discard, nil)), cfg)
+}
+
+func TestUserCategoriesChanged(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(h *CategoryHandler) error
+		want  []int
+	}{
+		{"create", func(h *CategoryHandler) error {
+			_, err := h.CreateCategory(withClaims("7"), &api.CategoryInput{Name: "books", UserID: 7})
+			return err
+		}, []int{7}},
+		{"update", func(h *CategoryHandler) error {
+			_, err := h.UpdateCategory(withClaims("7"), &api.CategoryInput{Name: "films", UserID: 7}, api.UpdateCategoryParams{ID: 1})
+			return err
+		}, []int{7}},
+		{"patch handing over", func(h *CategoryHandler) error {
+			_, err := h.PatchCategory(withClaims("1", authz.RoleAdmin), &api.CategoryPatch{UserID: api.NewOptInt(8)}, api.PatchCategoryParams{ID: 1})
+			return err
+		}, []int{7, 8}},
+		{"delete", func(h *CategoryHandler) error {
+			_, err := h.DeleteCategory(withClaims("7"), api.DeleteCategoryParams{ID: 1})
+			return err
+		}, []int{7}},
+		{"denied", func(h *CategoryHandler) error {
+			_, _ = h.DeleteCategory(withClaims("8"), api.DeleteCategoryParams{ID: 1})
+			return nil
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changed []int
+			h := newTestHandler(newMemStore(store.CategoryResult{ID: 1, Name: "books", UserID: 7}), Config{
+				OnUserCategoriesChanged: func(userID int) { changed = append(changed, userID) },
+			})
+			if err := tt.write(h); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if !reflect.DeepEqual(changed, tt.want) {
+				t.Errorf("changed users = %v, want %v", changed, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCategoryByIdForbidsOtherUsers(t *testing.T) {
+	h := newTestHandler(newMemStore(
+		store.CategoryResult{ID: 1, Name: "books", UserID: 7},
+		store.CategoryResult{ID: 2, Name: "films", UserID: 8},
+	), Config{})
+	ctx := withClaims("7")
+
+	res, err := h.GetCategoryById(ctx, api.GetCategoryByIdParams{ID: 1})
+	if err != nil {
+		t.Fatalf("own category: %v", err)
+	}
+	if c := res.(*api.Category); c.ID != 1 || c.UserID != 7 {
+		t.Errorf("own category = %+v", c)
+	}
+
+	_, err = h.GetCategoryById(ctx, api.GetCategoryByIdParams{ID: 2})
+	if got := h.NewError(ctx, err).StatusCode; got != http.StatusForbidden {
+		t.Errorf("other user's category: status = %d, want %d", got, http.StatusForbidden)
+	}
+	_, err = h.GetCategoryById(ctx, api.GetCategoryByIdParams{ID: 3})
+	if got := h.NewError(ctx, err).StatusCode; got != http.StatusNotFound {
+		t.Errorf("missing category: status = %d, want %d", got, http.StatusNotFound)
+	}
+
+	// The writes are forbidden the same way.
+	_, err = h.DeleteCategory(ctx, api.DeleteCategoryParams{ID: 2})
+	if !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("deleting other user's category: error = %v, want ErrForbidden", err)
+	}
+}
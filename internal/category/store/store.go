@@ -3,39 +3,138 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	db "github.com/opplieam/dist-mono/db/sqlc"
+	"github.com/opplieam/dist-mono/internal/apperr"
 )
 
 var (
-	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryNotFound = apperr.New(apperr.KindNotFound, "category not found")
 )
 
 type Store struct {
-	db *db.Queries
+	db  *db.Queries
+	log *slog.Logger
 }
 
-func NewStore(q *db.Queries) *Store {
+func NewStore(q *db.Queries, log *slog.Logger) *Store {
 	return &Store{
-		db: q,
+		db:  q,
+		log: log,
 	}
 }
 
 type CategoryResult struct {
-	ID   int
-	Name string
+	ID     int
+	Name   string
+	UserID int
 }
 
-func (s *Store) GetCategoryByID(ctx context.Context, userID int) (*CategoryResult, error) {
-	res, err := s.db.GetCategoryByID(ctx, int32(userID))
+// CategoryPatch holds the fields of a partial update, a nil field is left unchanged.
+type CategoryPatch struct {
+	Name   *string
+	UserID *int
+}
+
+func toCategoryResult(c db.Category) *CategoryResult {
+	return &CategoryResult{
+		ID:     int(c.ID),
+		Name:   c.Name,
+		UserID: int(c.UserID),
+	}
+}
+
+func (s *Store) GetCategoryByID(ctx context.Context, id int) (*CategoryResult, error) {
+	res, err := s.db.GetCategoryByID(ctx, int32(id))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
+			s.log.DebugContext(ctx, "Category not found", "category_id", id)
 			return nil, ErrCategoryNotFound
 		}
+		return nil, fmt.Errorf("get category %d: %w", id, err)
 	}
-	return &CategoryResult{
-		ID:   int(res.ID),
-		Name: res.Name,
-	}, nil
+	return toCategoryResult(res), nil
+}
+
+func (s *Store) ListCategories(ctx context.Context) ([]CategoryResult, error) {
+	categories, err := s.db.ListCategories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list categories: %w", err)
+	}
+	results := make([]CategoryResult, 0, len(categories))
+	for _, c := range categories {
+		results = append(results, *toCategoryResult(c))
+	}
+	return results, nil
+}
+
+func (s *Store) ListCategoriesByUserID(ctx context.Context, userID int) ([]CategoryResult, error) {
+	categories, err := s.db.ListCategoriesByUserID(ctx, int32(userID))
+	if err != nil {
+		return nil, fmt.Errorf("list categories of user %d: %w", userID, err)
+	}
+	results := make([]CategoryResult, 0, len(categories))
+	for _, c := range categories {
+		results = append(results, *toCategoryResult(c))
+	}
+	return results, nil
+}
+
+func (s *Store) CreateCategory(ctx context.Context, name string, userID int) (*CategoryResult, error) {
+	res, err := s.db.CreateCategory(ctx, db.CreateCategoryParams{
+		Name:   name,
+		UserID: int32(userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create category for user %d: %w", userID, err)
+	}
+	return toCategoryResult(res), nil
+}
+
+func (s *Store) UpdateCategory(ctx context.Context, id int, name string, userID int) (*CategoryResult, error) {
+	res, err := s.db.UpdateCategory(ctx, db.UpdateCategoryParams{
+		ID:     int32(id),
+		Name:   name,
+		UserID: int32(userID),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("update category %d: %w", id, err)
+	}
+	return toCategoryResult(res), nil
+}
+
+func (s *Store) PatchCategory(ctx context.Context, id int, patch CategoryPatch) (*CategoryResult, error) {
+	params := db.PatchCategoryParams{ID: int32(id)}
+	if patch.Name != nil {
+		params.Name = pgtype.Text{String: *patch.Name, Valid: true}
+	}
+	if patch.UserID != nil {
+		params.UserID = pgtype.Int4{Int32: int32(*patch.UserID), Valid: true}
+	}
+	res, err := s.db.PatchCategory(ctx, params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("patch category %d: %w", id, err)
+	}
+	return toCategoryResult(res), nil
+}
+
+func (s *Store) DeleteCategory(ctx context.Context, id int) error {
+	affected, err := s.db.DeleteCategory(ctx, int32(id))
+	if err != nil {
+		return fmt.Errorf("delete category %d: %w", id, err)
+	}
+	if affected == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
 }
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	db "github.com/opplieam/dist-mono/db/sqlc"
+	"github.com/opplieam/dist-mono/internal/apperr"
+)
+
+// failingDB is a db.DBTX where every call fails with err.
+type failingDB struct {
+	err error
+}
+
+func (f failingDB) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, f.err
+}
+
+func (f failingDB) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return nil, f.err
+}
+
+func (f failingDB) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return failingRow{err: f.err}
+}
+
+type failingRow struct {
+	err error
+}
+
+func (r failingRow) Scan(...any) error {
+	return r.err
+}
+
+type errCase struct {
+	name     string
+	dbErr    error
+	wantKind apperr.Kind
+	wantIs   error
+}
+
+func TestStoreErrors(t *testing.T) {
+	errConn := errors.New("connection reset by peer")
+	errSerialization := &pgconn.PgError{Code: "40001"}
+	name := "books"
+
+	calls := []struct {
+		name     string
+		notFound bool // whether pgx.ErrNoRows means the category doesn't exist
+		call     func(s *Store) error
+	}{
+		{"GetCategoryByID", true, func(s *Store) error {
+			_, err := s.GetCategoryByID(context.Background(), 1)
+			return err
+		}},
+		{"ListCategories", false, func(s *Store) error {
+			_, err := s.ListCategories(context.Background())
+			return err
+		}},
+		{"ListCategoriesByUserID", false, func(s *Store) error {
+			_, err := s.ListCategoriesByUserID(context.Background(), 1)
+			return err
+		}},
+		{"CreateCategory", false, func(s *Store) error {
+			_, err := s.CreateCategory(context.Background(), name, 1)
+			return err
+		}},
+		{"UpdateCategory", true, func(s *Store) error {
+			_, err := s.UpdateCategory(context.Background(), 1, name, 1)
+			return err
+		}},
+		{"PatchCategory", true, func(s *Store) error {
+			_, err := s.PatchCategory(context.Background(), 1, CategoryPatch{Name: &name})
+			return err
+		}},
+		{"DeleteCategory", false, func(s *Store) error {
+			return s.DeleteCategory(context.Background(), 1)
+		}},
+	}
+
+	for _, c := range calls {
+		tests := []errCase{
+			{"connection error", errConn, apperr.KindInternal, errConn},
+			{"serialization failure", errSerialization, apperr.KindConflict, errSerialization},
+		}
+		if c.notFound {
+			tests = append(tests, errCase{"no rows", pgx.ErrNoRows, apperr.KindNotFound, ErrCategoryNotFound})
+		}
+
+		for _, tt := range tests {
+			t.Run(c.name+"/"+tt.name, func(t *testing.T) {
+				s := NewStore(db.New(failingDB{err: tt.dbErr}), slog.New(slog.NewTextHandler(io.Discard, nil)))
+				err := c.call(s)
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				if !errors.Is(err, tt.wantIs) {
+					t.Errorf("error %q doesn't wrap %q", err, tt.wantIs)
+				}
+				if got := apperr.Translate(err).Kind; got != tt.wantKind {
+					t.Errorf("kind = %v, want %v", got, tt.wantKind)
+				}
+			})
+		}
+	}
+}
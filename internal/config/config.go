@@ -0,0 +1,550 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+// Config is the effective configuration of the server. Every leaf field is resolved in order
+// from the defaults, the optional YAML file, the env var named by the env tag and the flag named
+// by the flag tag, the last one set wins.
+type Config struct {
+	Target          string        `yaml:"target" env:"TARGET" flag:"target" usage:"Services to run (user, category, a comma-separated list of them or all), or migrate"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"Maximum time each component gets to stop on shutdown"`
+	DrainPeriod     time.Duration `yaml:"drain_period" env:"DRAIN_PERIOD" flag:"drain-period" usage:"Time readiness fails before the server stops accepting requests on shutdown"`
+
+	Log       LogConfig       `yaml:"log"`
+	DB        DBConfig        `yaml:"db"`
+	Otel      OtelConfig      `yaml:"otel"`
+	Health    HealthConfig    `yaml:"health"`
+	Auth      AuthConfig      `yaml:"auth"`
+	TLS       TLSConfig       `yaml:"tls"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	User      UserConfig      `yaml:"user"`
+	Category  CategoryConfig  `yaml:"category"`
+
+	// Args holds the positional arguments left after the flags.
+	Args []string `yaml:"-"`
+	// PrintConfig asks to dump the effective configuration and exit.
+	PrintConfig bool `yaml:"-"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"Log level (debug, info, warn or error)"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"Log format (json or text)"`
+}
+
+type DBConfig struct {
+	DSN               string        `yaml:"dsn" env:"DB_DSN" flag:"db-dsn" usage:"Postgres connection string" secret:"true"`
+	MaxConns          int           `yaml:"max_conns" env:"DB_MAX_CONNS" flag:"db-max-conns" usage:"Maximum number of DB connections in the pool"`
+	MinConns          int           `yaml:"min_conns" env:"DB_MIN_CONNS" flag:"db-min-conns" usage:"Minimum number of idle DB connections kept in the pool"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME" flag:"db-max-conn-lifetime" usage:"Maximum lifetime of a DB connection"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period" env:"DB_HEALTH_CHECK_PERIOD" flag:"db-health-check-period" usage:"Interval between health checks of idle DB connections"`
+	AcquireTimeout    time.Duration `yaml:"acquire_timeout" env:"DB_ACQUIRE_TIMEOUT" flag:"db-acquire-timeout" usage:"Maximum time to wait for a free DB connection, 0 disables it"`
+}
+
+type OtelConfig struct {
+	Endpoint       string        `yaml:"endpoint" env:"OTEL_ENDPOINT" flag:"otel-endpoint" usage:"OTLP gRPC collector endpoint"`
+	MetricInterval time.Duration `yaml:"metric_interval" env:"OTEL_METRIC_INTERVAL" flag:"otel-metric-interval" usage:"Interval between metric exports"`
+	Timeout        time.Duration `yaml:"timeout" env:"OTEL_TIMEOUT" flag:"otel-timeout" usage:"Timeout for connecting and flushing the OTLP exporters"`
+}
+
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" flag:"health-check-timeout" usage:"Deadline of a single readiness check"`
+}
+
+type AuthConfig struct {
+	JWKSFile       string        `yaml:"jwks_file" env:"AUTH_JWKS_FILE" flag:"auth-jwks-file" usage:"Path of a local JWKS file with the token signing keys"`
+	JWKSURL        string        `yaml:"jwks_url" env:"AUTH_JWKS_URL" flag:"auth-jwks-url" usage:"JWKS endpoint of the identity provider"`
+	JWKSRefresh    time.Duration `yaml:"jwks_refresh" env:"AUTH_JWKS_REFRESH" flag:"auth-jwks-refresh" usage:"Time the keys fetched from the JWKS endpoint are cached"`
+	JWKSMinRefresh time.Duration `yaml:"jwks_min_refresh" env:"AUTH_JWKS_MIN_REFRESH" flag:"auth-jwks-min-refresh" usage:"Minimum time between two JWKS fetches triggered by an unknown key"`
+	JWKSTimeout    time.Duration `yaml:"jwks_timeout" env:"AUTH_JWKS_TIMEOUT" flag:"auth-jwks-timeout" usage:"Deadline of a JWKS endpoint fetch"`
+	Issuer         string        `yaml:"issuer" env:"AUTH_ISSUER" flag:"auth-issuer" usage:"Expected iss claim of the tokens"`
+	Audience       string        `yaml:"audience" env:"AUTH_AUDIENCE" flag:"auth-audience" usage:"Expected aud claim of the tokens"`
+	Leeway         time.Duration `yaml:"leeway" env:"AUTH_LEEWAY" flag:"auth-leeway" usage:"Clock skew tolerated on the token validity period"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE" flag:"tls-cert-file" usage:"PEM certificate of the services, also presented to the category service by the user service, empty serves plain HTTP"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE" flag:"tls-key-file" usage:"PEM private key of the TLS certificate"`
+	CAFile   string `yaml:"ca_file" env:"TLS_CA_FILE" flag:"tls-ca-file" usage:"PEM CA bundle verifying the client certificates and the category service certificate"`
+}
+
+type RateLimitConfig struct {
+	Rate         float64 `yaml:"rate" env:"RATE_LIMIT_RATE" flag:"rate-limit-rate" usage:"Requests per second a client can send to each operation, 0 disables rate limiting"`
+	Burst        int     `yaml:"burst" env:"RATE_LIMIT_BURST" flag:"rate-limit-burst" usage:"Requests a client can send at once to each operation"`
+	APIKeyHeader string  `yaml:"api_key_header" env:"RATE_LIMIT_API_KEY_HEADER" flag:"rate-limit-api-key-header" usage:"Header with the API key clients are counted by, only set it behind a gateway validating the keys"`
+	// Operations overrides the limits by ogen operation name, e.g. CreateUser.
+	Operations map[string]RateLimitOperationConfig `yaml:"operations"`
+}
+
+type RateLimitOperationConfig struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+type UserConfig struct {
+	Addr              string               `yaml:"addr" env:"USER_ADDR" flag:"user-addr" usage:"Listen address of the user service"`
+	CategoryURL       string               `yaml:"category_url" env:"USER_CATEGORY_URL" flag:"user-category-url" usage:"Base URL of the category service"`
+	CategoryInProcess bool                 `yaml:"category_in_process" env:"USER_CATEGORY_IN_PROCESS" flag:"user-category-in-process" usage:"Call the category service in-process when both run in this process"`
+	StrictCategory    bool                 `yaml:"strict_category" env:"USER_STRICT_CATEGORY" flag:"user-strict-category" usage:"Fail user lookups when the category service fails instead of omitting the categories"`
+	CategoryClient    CategoryClientConfig `yaml:"category_client"`
+	CategoryCache     CategoryCacheConfig  `yaml:"category_cache"`
+	ServiceToken      ServiceTokenConfig   `yaml:"service_token"`
+	Idempotency       IdempotencyConfig    `yaml:"idempotency"`
+}
+
+type IdempotencyConfig struct {
+	TTL           time.Duration `yaml:"ttl" env:"USER_IDEMPOTENCY_TTL" flag:"user-idempotency-ttl" usage:"Time the response of a request with an Idempotency-Key is replayed"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"USER_IDEMPOTENCY_PURGE_INTERVAL" flag:"user-idempotency-purge-interval" usage:"Interval between two deletions of the expired idempotency keys"`
+}
+
+type ServiceTokenConfig struct {
+	KeyFile  string        `yaml:"key_file" env:"USER_SERVICE_TOKEN_KEY_FILE" flag:"user-service-token-key-file" usage:"PEM private key signing the service tokens sent to the category service, empty sends none"`
+	KeyID    string        `yaml:"key_id" env:"USER_SERVICE_TOKEN_KEY_ID" flag:"user-service-token-key-id" usage:"kid header of the service tokens"`
+	Identity string        `yaml:"identity" env:"USER_SERVICE_TOKEN_IDENTITY" flag:"user-service-token-identity" usage:"Caller identity of the user service, the sub claim of its service tokens"`
+	TTL      time.Duration `yaml:"ttl" env:"USER_SERVICE_TOKEN_TTL" flag:"user-service-token-ttl" usage:"Lifetime of a service token"`
+}
+
+type CategoryCacheConfig struct {
+	Size        int           `yaml:"size" env:"CATEGORY_CACHE_SIZE" flag:"category-cache-size" usage:"Maximum number of users whose categories are cached, 0 disables the cache"`
+	TTL         time.Duration `yaml:"ttl" env:"CATEGORY_CACHE_TTL" flag:"category-cache-ttl" usage:"Time a category lookup stays cached, the longest it can be stale unless the category service runs in-process"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" env:"CATEGORY_CACHE_NEGATIVE_TTL" flag:"category-cache-negative-ttl" usage:"Time a user without categories stays cached"`
+}
+
+type CategoryClientConfig struct {
+	Timeout                 time.Duration `yaml:"timeout" env:"CATEGORY_CLIENT_TIMEOUT" flag:"category-client-timeout" usage:"Overall deadline of a category service call, retries included"`
+	AttemptTimeout          time.Duration `yaml:"attempt_timeout" env:"CATEGORY_CLIENT_ATTEMPT_TIMEOUT" flag:"category-client-attempt-timeout" usage:"Deadline of a single category service attempt"`
+	MaxRetries              int           `yaml:"max_retries" env:"CATEGORY_CLIENT_MAX_RETRIES" flag:"category-client-max-retries" usage:"Maximum retries of an idempotent category service call"`
+	RetryBaseDelay          time.Duration `yaml:"retry_base_delay" env:"CATEGORY_CLIENT_RETRY_BASE_DELAY" flag:"category-client-retry-base-delay" usage:"Base delay of the exponential retry backoff"`
+	RetryMaxDelay           time.Duration `yaml:"retry_max_delay" env:"CATEGORY_CLIENT_RETRY_MAX_DELAY" flag:"category-client-retry-max-delay" usage:"Maximum delay between two retries"`
+	BreakerFailureThreshold int           `yaml:"breaker_failure_threshold" env:"CATEGORY_CLIENT_BREAKER_FAILURE_THRESHOLD" flag:"category-client-breaker-failure-threshold" usage:"Consecutive failures opening the circuit breaker"`
+	BreakerOpenTimeout      time.Duration `yaml:"breaker_open_timeout" env:"CATEGORY_CLIENT_BREAKER_OPEN_TIMEOUT" flag:"category-client-breaker-open-timeout" usage:"Time the circuit breaker stays open before probing"`
+	BreakerHalfOpenProbes   int           `yaml:"breaker_half_open_probes" env:"CATEGORY_CLIENT_BREAKER_HALF_OPEN_PROBES" flag:"category-client-breaker-half-open-probes" usage:"Concurrent probe calls allowed while half-open"`
+}
+
+type CategoryConfig struct {
+	Addr                 string   `yaml:"addr" env:"CATEGORY_ADDR" flag:"category-addr" usage:"Listen address of the category service"`
+	RequireClientCert    bool     `yaml:"require_client_cert" env:"CATEGORY_REQUIRE_CLIENT_CERT" flag:"category-require-client-cert" usage:"Reject the connections without a client certificate signed by the TLS CA"`
+	AllowedCallers       []string `yaml:"allowed_callers" env:"CATEGORY_ALLOWED_CALLERS" flag:"category-allowed-callers" usage:"Comma-separated identities of the services allowed to call the category service, empty allows any caller"`
+	ServiceTokenJWKSFile string   `yaml:"service_token_jwks_file" env:"CATEGORY_SERVICE_TOKEN_JWKS_FILE" flag:"category-service-token-jwks-file" usage:"Path of a JWKS file verifying the service tokens of the callers"`
+}
+
+func Default() Config {
+	return Config{
+		ShutdownTimeout: 5 * time.Second,
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		DB: DBConfig{
+			MaxConns:          10,
+			MaxConnLifetime:   time.Hour,
+			HealthCheckPeriod: time.Minute,
+			AcquireTimeout:    5 * time.Second,
+		},
+		Otel: OtelConfig{
+			Endpoint:       "localhost:4317",
+			MetricInterval: 5 * time.Second,
+			Timeout:        5 * time.Second,
+		},
+		Health: HealthConfig{
+			CheckTimeout: 2 * time.Second,
+		},
+		Auth: AuthConfig{
+			JWKSRefresh:    15 * time.Minute,
+			JWKSMinRefresh: time.Minute,
+			JWKSTimeout:    5 * time.Second,
+			Leeway:         30 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			Rate:  20,
+			Burst: 40,
+		},
+		User: UserConfig{
+			Addr:        ":3000",
+			CategoryURL: "http://localhost:4000/v1",
+			CategoryClient: CategoryClientConfig{
+				Timeout:                 5 * time.Second,
+				AttemptTimeout:          2 * time.Second,
+				MaxRetries:              2,
+				RetryBaseDelay:          100 * time.Millisecond,
+				RetryMaxDelay:           time.Second,
+				BreakerFailureThreshold: 5,
+				BreakerOpenTimeout:      10 * time.Second,
+				BreakerHalfOpenProbes:   1,
+			},
+			CategoryCache: CategoryCacheConfig{
+				Size:        10000,
+				TTL:         time.Minute,
+				NegativeTTL: 10 * time.Second,
+			},
+			ServiceToken: ServiceTokenConfig{
+				Identity: "user",
+				TTL:      5 * time.Minute,
+			},
+			Idempotency: IdempotencyConfig{
+				TTL:           24 * time.Hour,
+				PurgeInterval: time.Hour,
+			},
+		},
+		Category: CategoryConfig{
+			Addr: ":4000",
+		},
+	}
+}
+
+// Load resolves the configuration from the command line arguments, the environment and the YAML
+// file given by -config or CONFIG_FILE.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "Path to a YAML config file")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "Print the effective config with secrets redacted and exit")
+
+	fields := leafFields(reflect.ValueOf(&cfg).Elem())
+	flagFields := make(map[string]reflect.Value)
+	for _, f := range fields {
+		name := f.tag.Get("flag")
+		if name == "" {
+			continue
+		}
+		if f.value.Kind() == reflect.Bool {
+			fs.Bool(name, f.value.Bool(), f.tag.Get("usage"))
+		} else {
+			fs.String(name, formatValue(f.value), f.tag.Get("usage"))
+		}
+		flagFields[name] = f.value
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.Args = fs.Args()
+
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		name := f.tag.Get("env")
+		if name == "" {
+			continue
+		}
+		if raw, ok := os.LookupEnv(name); ok {
+			if err := setValue(f.value, raw); err != nil {
+				return nil, fmt.Errorf("invalid env %s: %w", name, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		v, ok := flagFields[fl.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		if err := setValue(v, fl.Value.String()); err != nil {
+			flagErr = fmt.Errorf("invalid flag -%s: %w", fl.Name, err)
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	// An invalid config is still printed, to help finding what is wrong with it.
+	if cfg.PrintConfig {
+		return &cfg, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err = dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Target != "migrate" && c.Target != "all" {
+		for _, name := range strings.Split(c.Target, ",") {
+			if !slices.Contains(services, strings.TrimSpace(name)) {
+				errs = append(errs, fmt.Errorf("invalid target %q: must be 'user', 'category', a comma-separated list of them, 'all' or 'migrate'", c.Target))
+				break
+			}
+		}
+	}
+	if c.User.CategoryInProcess && !(slices.Contains(c.Services(), "user") && slices.Contains(c.Services(), "category")) {
+		errs = append(errs, errors.New("user category in process requires the user and category services in the target"))
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("invalid log level %q", c.Log.Level))
+	}
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("invalid log format %q", c.Log.Format))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown timeout must be positive"))
+	}
+	if c.DrainPeriod < 0 {
+		errs = append(errs, errors.New("drain period must not be negative"))
+	}
+
+	if c.DB.DSN == "" {
+		errs = append(errs, errors.New("db dsn is required"))
+	}
+	if c.DB.MaxConns < 1 {
+		errs = append(errs, errors.New("db max conns must be at least 1"))
+	}
+	if c.DB.MinConns < 0 || c.DB.MinConns > c.DB.MaxConns {
+		errs = append(errs, errors.New("db min conns must be between 0 and db max conns"))
+	}
+	if c.DB.AcquireTimeout < 0 {
+		errs = append(errs, errors.New("db acquire timeout must not be negative"))
+	}
+
+	if c.Otel.Endpoint == "" {
+		errs = append(errs, errors.New("otel endpoint is required"))
+	}
+	if c.Otel.MetricInterval <= 0 || c.Otel.Timeout <= 0 {
+		errs = append(errs, errors.New("otel metric interval and timeout must be positive"))
+	}
+	if c.Health.CheckTimeout <= 0 {
+		errs = append(errs, errors.New("health check timeout must be positive"))
+	}
+
+	if c.Target != "migrate" {
+		if (c.Auth.JWKSFile == "") == (c.Auth.JWKSURL == "") {
+			errs = append(errs, errors.New("exactly one of auth jwks file and auth jwks url is required"))
+		}
+		if c.Auth.Issuer == "" || c.Auth.Audience == "" {
+			errs = append(errs, errors.New("auth issuer and audience are required"))
+		}
+	}
+	if c.Auth.JWKSRefresh <= 0 || c.Auth.JWKSMinRefresh <= 0 || c.Auth.JWKSTimeout <= 0 || c.Auth.Leeway < 0 {
+		errs = append(errs, errors.New("auth jwks refresh intervals and timeout must be positive and the leeway not negative"))
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls cert file and key file must be set together"))
+	}
+	if c.Category.RequireClientCert && (c.TLS.CertFile == "" || c.TLS.CAFile == "") {
+		errs = append(errs, errors.New("category require client cert needs the tls cert, key and ca files"))
+	}
+	if len(c.Category.AllowedCallers) > 0 && c.TLS.CAFile == "" && c.Category.ServiceTokenJWKSFile == "" {
+		errs = append(errs, errors.New("category allowed callers need client certificates or service tokens to identify the callers"))
+	}
+	// In-process calls don't go through TLS, so the user service can only be identified by its
+	// service token.
+	if len(c.Category.AllowedCallers) > 0 && c.User.CategoryInProcess && (c.Category.ServiceTokenJWKSFile == "" || c.User.ServiceToken.KeyFile == "") {
+		errs = append(errs, errors.New("category allowed callers with an in-process category service need the service token jwks file and the user service token key file"))
+	}
+	if c.User.ServiceToken.KeyFile != "" && (c.User.ServiceToken.Identity == "" || c.User.ServiceToken.TTL <= 0) {
+		errs = append(errs, errors.New("user service token identity is required and its ttl must be positive"))
+	}
+
+	if c.RateLimit.Rate < 0 || (c.RateLimit.Rate > 0 && c.RateLimit.Burst < 1) {
+		errs = append(errs, errors.New("rate limit rate must not be negative and its burst must be positive"))
+	}
+	for op, limit := range c.RateLimit.Operations {
+		if limit.Rate < 0 || (limit.Rate > 0 && limit.Burst < 1) {
+			errs = append(errs, fmt.Errorf("rate limit of operation %s: rate must not be negative and burst must be positive", op))
+		}
+	}
+
+	if c.User.Addr == "" || c.Category.Addr == "" {
+		errs = append(errs, errors.New("listen addresses are required"))
+	}
+	if u, err := url.Parse(c.User.CategoryURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid category url %q", c.User.CategoryURL))
+	}
+	cc := c.User.CategoryClient
+	if cc.Timeout <= 0 || cc.AttemptTimeout <= 0 {
+		errs = append(errs, errors.New("category client timeouts must be positive"))
+	}
+	if cc.MaxRetries < 0 || cc.RetryBaseDelay < 0 || cc.RetryMaxDelay < cc.RetryBaseDelay {
+		errs = append(errs, errors.New("category client retries must not be negative and the max delay not below the base delay"))
+	}
+	if cc.BreakerFailureThreshold < 1 || cc.BreakerHalfOpenProbes < 1 || cc.BreakerOpenTimeout <= 0 {
+		errs = append(errs, errors.New("category client breaker threshold, probes and open timeout must be positive"))
+	}
+	if c.User.Idempotency.TTL <= 0 || c.User.Idempotency.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("user idempotency ttl and purge interval must be positive"))
+	}
+	if c.User.CategoryCache.Size < 0 || c.User.CategoryCache.TTL <= 0 || c.User.CategoryCache.NegativeTTL <= 0 {
+		errs = append(errs, errors.New("category cache size must not be negative and its ttls must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// services lists the services the server can run, in start order. The category service starts
+// first so an in-process category client is ready for the user service.
+var services = []string{"category", "user"}
+
+// Services returns the services selected by Target in start order, none for migrate.
+func (c *Config) Services() []string {
+	if c.Target == "all" {
+		return slices.Clone(services)
+	}
+	var selected []string
+	for _, name := range services {
+		for _, t := range strings.Split(c.Target, ",") {
+			if strings.TrimSpace(t) == name {
+				selected = append(selected, name)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+var dsnPasswordRe = regexp.MustCompile(`password=\S+`)
+
+// Redacted returns a copy of the config with every secret field masked.
+func (c Config) Redacted() Config {
+	for _, f := range leafFields(reflect.ValueOf(&c).Elem()) {
+		if f.tag.Get("secret") != "true" || f.value.Kind() != reflect.String || f.value.String() == "" {
+			continue
+		}
+		f.value.SetString(redactSecret(f.value.String()))
+	}
+	return c
+}
+
+func redactSecret(s string) string {
+	// Keep the non-secret parts of connection strings readable.
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		return u.String()
+	}
+	if dsnPasswordRe.MatchString(s) {
+		return dsnPasswordRe.ReplaceAllString(s, "password="+redacted)
+	}
+	return redacted
+}
+
+// Print writes the effective config as YAML with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+type leafField struct {
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+func leafFields(v reflect.Value) []leafField {
+	var fields []leafField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		if sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, leafFields(v.Field(i))...)
+			continue
+		}
+		fields = append(fields, leafField{value: v.Field(i), tag: sf.Tag})
+	}
+	return fields
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,248 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type PoolConfig struct {
+	DSN               string
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	HealthCheckPeriod time.Duration
+	// AcquireTimeout bounds how long a query waits for a free connection, zero means no limit.
+	AcquireTimeout time.Duration
+}
+
+// Pool wraps pgxpool.Pool to apply the acquire timeout. It satisfies db.DBTX.
+type Pool struct {
+	*pgxpool.Pool
+	acquireTimeout time.Duration
+}
+
+func NewPool(ctx context.Context, cfg PoolConfig) (*Pool, error) {
+	pgCfg, err := pgxpool.ParseConfig(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse db dsn: %w", err)
+	}
+	if cfg.MaxConns > 0 {
+		pgCfg.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		pgCfg.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		pgCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.HealthCheckPeriod > 0 {
+		pgCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, pgCfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create db pool: %w", err)
+	}
+	return &Pool{
+		Pool:           pool,
+		acquireTimeout: cfg.AcquireTimeout,
+	}, nil
+}
+
+func (p *Pool) acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if p.acquireTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.acquireTimeout)
+		defer cancel()
+	}
+	conn, err := p.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to acquire db connection: %w", err)
+	}
+	return conn, nil
+}
+
+func (p *Pool) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if p.acquireTimeout == 0 {
+		return p.Pool.Exec(ctx, sql, args...)
+	}
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer conn.Release()
+	return conn.Exec(ctx, sql, args...)
+}
+
+func (p *Pool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if p.acquireTimeout == 0 {
+		return p.Pool.Query(ctx, sql, args...)
+	}
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &poolRows{Rows: rows, conn: conn}, nil
+}
+
+func (p *Pool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if p.acquireTimeout == 0 {
+		return p.Pool.QueryRow(ctx, sql, args...)
+	}
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return &poolRow{row: conn.QueryRow(ctx, sql, args...), conn: conn}
+}
+
+func (p *Pool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return p.BeginTx(ctx, pgx.TxOptions{})
+}
+
+func (p *Pool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	if p.acquireTimeout == 0 {
+		return p.Pool.BeginTx(ctx, txOptions)
+	}
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTx(ctx, txOptions)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &poolTx{Tx: tx, conn: conn}, nil
+}
+
+// poolTx releases the connection back to the pool once the transaction is committed or rolled
+// back.
+type poolTx struct {
+	pgx.Tx
+	conn *pgxpool.Conn
+}
+
+func (t *poolTx) Commit(ctx context.Context) error {
+	err := t.Tx.Commit(ctx)
+	t.release()
+	return err
+}
+
+func (t *poolTx) Rollback(ctx context.Context) error {
+	err := t.Tx.Rollback(ctx)
+	t.release()
+	return err
+}
+
+func (t *poolTx) release() {
+	if t.conn != nil {
+		t.conn.Release()
+		t.conn = nil
+	}
+}
+
+// poolRows releases the connection back to the pool once the rows are closed.
+type poolRows struct {
+	pgx.Rows
+	conn *pgxpool.Conn
+}
+
+func (r *poolRows) Close() {
+	r.Rows.Close()
+	if r.conn != nil {
+		r.conn.Release()
+		r.conn = nil
+	}
+}
+
+func (r *poolRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+// poolRow releases the connection back to the pool once the row is scanned.
+type poolRow struct {
+	row  pgx.Row
+	conn *pgxpool.Conn
+}
+
+func (r *poolRow) Scan(dest ...any) error {
+	defer r.conn.Release()
+	return r.row.Scan(dest...)
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
+
+// RegisterMetrics exposes the pool statistics as OTel observable instruments.
+func (p *Pool) RegisterMetrics() error {
+	meter := otel.GetMeterProvider().Meter("database")
+
+	total, err := meter.Int64ObservableGauge("db.pool.connections.total", metric.WithDescription("Total connections in the pool"))
+	if err != nil {
+		return err
+	}
+	idle, err := meter.Int64ObservableGauge("db.pool.connections.idle", metric.WithDescription("Idle connections in the pool"))
+	if err != nil {
+		return err
+	}
+	acquired, err := meter.Int64ObservableGauge("db.pool.connections.acquired", metric.WithDescription("Connections currently in use"))
+	if err != nil {
+		return err
+	}
+	maxConns, err := meter.Int64ObservableGauge("db.pool.connections.max", metric.WithDescription("Maximum size of the pool"))
+	if err != nil {
+		return err
+	}
+	acquireCount, err := meter.Int64ObservableCounter("db.pool.acquires", metric.WithDescription("Successful connection acquires"))
+	if err != nil {
+		return err
+	}
+	emptyAcquireCount, err := meter.Int64ObservableCounter("db.pool.acquires.waited", metric.WithDescription("Acquires that had to wait for a connection"))
+	if err != nil {
+		return err
+	}
+	canceledAcquireCount, err := meter.Int64ObservableCounter("db.pool.acquires.canceled", metric.WithDescription("Acquires canceled by their context"))
+	if err != nil {
+		return err
+	}
+	acquireDuration, err := meter.Float64ObservableCounter("db.pool.acquire.duration", metric.WithDescription("Total time spent acquiring connections"), metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		stat := p.Stat()
+		o.ObserveInt64(total, int64(stat.TotalConns()))
+		o.ObserveInt64(idle, int64(stat.IdleConns()))
+		o.ObserveInt64(acquired, int64(stat.AcquiredConns()))
+		o.ObserveInt64(maxConns, int64(stat.MaxConns()))
+		o.ObserveInt64(acquireCount, stat.AcquireCount())
+		o.ObserveInt64(emptyAcquireCount, stat.EmptyAcquireCount())
+		o.ObserveInt64(canceledAcquireCount, stat.CanceledAcquireCount())
+		o.ObserveFloat64(acquireDuration, stat.AcquireDuration().Seconds())
+		return nil
+	}, total, idle, acquired, maxConns, acquireCount, emptyAcquireCount, canceledAcquireCount, acquireDuration)
+	return err
+}
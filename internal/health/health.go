@@ -0,0 +1,199 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusStarting     = "starting"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check is a named readiness check. A failing optional check is reported without failing the
+// readiness probe.
+type Check struct {
+	Name     string
+	Timeout  time.Duration
+	Optional bool
+	Check    func(ctx context.Context) error
+}
+
+type Result struct {
+	Status     string `json:"status"`
+	Optional   bool   `json:"optional,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Registry holds the readiness checks of a service and its lifecycle state. It serves the
+// liveness, readiness and startup probes.
+type Registry struct {
+	defaultTimeout time.Duration
+
+	mu     sync.RWMutex
+	checks []Check
+
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry creates a registry, defaultTimeout bounds the checks registered without a timeout.
+func NewRegistry(defaultTimeout time.Duration) *Registry {
+	return &Registry{defaultTimeout: defaultTimeout}
+}
+
+func (r *Registry) Register(c Check) {
+	if c.Timeout <= 0 {
+		c.Timeout = r.defaultTimeout
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, c)
+}
+
+// MarkStarted flips the startup probe to passing.
+func (r *Registry) MarkStarted() {
+	r.started.Store(true)
+}
+
+// MarkShuttingDown flips the readiness probe to failing, so load balancers stop sending traffic
+// while in-flight requests drain.
+func (r *Registry) MarkShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Run runs every check concurrently, each bounded by its own timeout.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]Check(nil), r.checks...)
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	for i, c := range checks {
+		report.Checks[c.Name] = results[i]
+		if results[i].Status != StatusOK && !c.Optional {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// The check ignored its context, don't let it hold the probe.
+		err = ctx.Err()
+	}
+	res := Result{Status: StatusOK, Optional: c.Optional, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// Liveness reports whether the process is up, it never runs the checks.
+func (r *Registry) Liveness(w http.ResponseWriter, _ *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// Readiness runs the checks, and fails without running them once shutdown has started.
+func (r *Registry) Readiness(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case r.shuttingDown.Load():
+		writeReport(w, http.StatusServiceUnavailable, Report{Status: StatusShuttingDown})
+	case !r.started.Load():
+		writeReport(w, http.StatusServiceUnavailable, Report{Status: StatusStarting})
+	default:
+		report := r.Run(req.Context())
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		writeReport(w, code, report)
+	}
+}
+
+// Startup passes once MarkStarted has been called.
+func (r *Registry) Startup(w http.ResponseWriter, _ *http.Request) {
+	if !r.started.Load() {
+		writeReport(w, http.StatusServiceUnavailable, Report{Status: StatusStarting})
+		return
+	}
+	writeReport(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// Mount registers the probes as /healthz, /readyz and /startupz.
+func (r *Registry) Mount(router chi.Router) {
+	router.Get("/healthz", r.Liveness)
+	router.Get("/readyz", r.Readiness)
+	router.Get("/startupz", r.Startup)
+}
+
+func writeReport(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// HTTPCheck returns a check that GETs url and fails unless it answers 2xx.
+func HTTPCheck(client *http.Client, url string) func(ctx context.Context) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,159 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Hook is a component managed by the Manager. Every field is optional.
+type Hook struct {
+	Name string
+	// Start runs in registration order, a failure stops the hooks already started.
+	Start func(ctx context.Context) error
+	// Serve runs in its own goroutine once every hook has started. It blocks until the component
+	// stops, and returning an error shuts the whole process down.
+	Serve func() error
+	// Drain is called as soon as shutdown begins, before the drain period, so the component can
+	// stop advertising itself (e.g. fail readiness).
+	Drain func()
+	// Stop runs in reverse registration order, bounded by Timeout or the manager's StopTimeout.
+	Stop    func(ctx context.Context) error
+	Timeout time.Duration
+}
+
+type Config struct {
+	// DrainPeriod is the wait between Drain and Stop, so load balancers notice the instance is
+	// going away before it stops accepting requests. It is skipped when a Serve hook failed.
+	DrainPeriod time.Duration
+	// StopTimeout bounds each Stop hook without its own timeout.
+	StopTimeout time.Duration
+}
+
+// Manager starts hooks in order, waits for a signal or a serve failure, then drains and stops
+// them in reverse order. A second signal during shutdown forces the process to exit.
+type Manager struct {
+	cfg   Config
+	log   *slog.Logger
+	hooks []Hook
+
+	// exit is called on a forced quit.
+	exit func(code int)
+}
+
+func New(log *slog.Logger, cfg Config) *Manager {
+	return &Manager{
+		cfg:  cfg,
+		log:  log,
+		exit: os.Exit,
+	}
+}
+
+func (m *Manager) Append(h Hook) {
+	m.hooks = append(m.hooks, h)
+}
+
+// Run starts the hooks and blocks until ctx is done, SIGINT or SIGTERM is received or a Serve
+// hook fails, then shuts everything down. It returns the start or serve error, joined with
+// any stop error.
+func (m *Manager) Run(ctx context.Context) error {
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	started, err := m.start(ctx)
+	if err != nil {
+		return errors.Join(err, m.stop(m.hooks[:started]))
+	}
+
+	serveErr := make(chan error, len(m.hooks))
+	for _, h := range m.hooks {
+		if h.Serve == nil {
+			continue
+		}
+		go func() {
+			if err := h.Serve(); err != nil {
+				serveErr <- fmt.Errorf("%s: %w", h.Name, err)
+			}
+		}()
+	}
+
+	var runErr error
+	select {
+	case sig := <-sigChan:
+		m.log.Info("Received signal, shutting down", "signal", sig.String())
+	case <-ctx.Done():
+		m.log.Info("Context done, shutting down", "error", ctx.Err())
+	case runErr = <-serveErr:
+		m.log.Error("Component failed, shutting down", "error", runErr)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-sigChan:
+			m.log.Error("Received second signal, forcing exit", "signal", sig.String())
+			m.exit(1)
+		case <-done:
+		}
+	}()
+
+	// A failed component already stops the process from serving, waiting for load balancers
+	// would only delay the restart.
+	m.drain(runErr == nil)
+	return errors.Join(runErr, m.stop(m.hooks))
+}
+
+func (m *Manager) start(ctx context.Context) (int, error) {
+	for i, h := range m.hooks {
+		if h.Start == nil {
+			continue
+		}
+		m.log.Debug("Starting component", "component", h.Name)
+		if err := h.Start(ctx); err != nil {
+			return i, fmt.Errorf("start %s: %w", h.Name, err)
+		}
+	}
+	return len(m.hooks), nil
+}
+
+// drain calls the Drain hooks, then waits for the drain period when wait is set.
+func (m *Manager) drain(wait bool) {
+	for _, h := range m.hooks {
+		if h.Drain != nil {
+			h.Drain()
+		}
+	}
+	if wait && m.cfg.DrainPeriod > 0 {
+		m.log.Info("Draining", "period", m.cfg.DrainPeriod)
+		time.Sleep(m.cfg.DrainPeriod)
+	}
+}
+
+// stop runs the Stop hooks in reverse order. A failing hook doesn't prevent the next ones.
+func (m *Manager) stop(hooks []Hook) error {
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if h.Stop == nil {
+			continue
+		}
+		timeout := h.Timeout
+		if timeout <= 0 {
+			timeout = m.cfg.StopTimeout
+		}
+		m.log.Debug("Stopping component", "component", h.Name)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := h.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", h.Name, err))
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
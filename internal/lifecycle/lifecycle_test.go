@@ -0,0 +1,165 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// recorder records the calls of the hooks in order.
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.calls)
+}
+
+// hook returns a hook recording its start and stop, failing to start with startErr.
+func (r *recorder) hook(name string, startErr error) Hook {
+	return Hook{
+		Name: name,
+		Start: func(context.Context) error {
+			r.record("start " + name)
+			return startErr
+		},
+		Stop: func(context.Context) error {
+			r.record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func newTestManager(cfg Config) *Manager {
+	if cfg.StopTimeout == 0 {
+		cfg.StopTimeout = time.Second
+	}
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg)
+}
+
+func TestRunOrder(t *testing.T) {
+	var rec recorder
+	m := newTestManager(Config{})
+	m.Append(rec.hook("db", nil))
+	m.Append(rec.hook("server", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []string{"start db", "start server", "stop server", "stop db"}
+	if got := rec.Calls(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestRunStartError(t *testing.T) {
+	var rec recorder
+	errStart := errors.New("cannot bind")
+	m := newTestManager(Config{})
+	m.Append(rec.hook("db", nil))
+	m.Append(rec.hook("server", errStart))
+	m.Append(rec.hook("worker", nil))
+
+	err := m.Run(context.Background())
+	if !errors.Is(err, errStart) {
+		t.Fatalf("Run error = %v, want the start error", err)
+	}
+	// Only the hooks started before the failing one are stopped.
+	want := []string{"start db", "start server", "stop db"}
+	if got := rec.Calls(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestRunServeError(t *testing.T) {
+	var rec recorder
+	errServe := errors.New("listener closed")
+	m := newTestManager(Config{DrainPeriod: time.Minute})
+	m.Append(rec.hook("db", nil))
+	server := rec.hook("server", nil)
+	server.Serve = func() error { return errServe }
+	server.Drain = func() { rec.record("drain server") }
+	m.Append(server)
+
+	done := make(chan error, 1)
+	go func() { done <- m.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errServe) {
+			t.Fatalf("Run error = %v, want the serve error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after a serve error, it waited for the drain period")
+	}
+	want := []string{"start db", "start server", "drain server", "stop server", "stop db"}
+	if got := rec.Calls(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestRunSecondSignalExits(t *testing.T) {
+	m := newTestManager(Config{StopTimeout: 5 * time.Second})
+	exitCode := -1
+	exited := make(chan struct{})
+	m.exit = func(code int) {
+		exitCode = code
+		close(exited)
+	}
+
+	serving := make(chan struct{})
+	stopping := make(chan struct{})
+	m.Append(Hook{
+		Name: "server",
+		Serve: func() error {
+			close(serving)
+			return nil
+		},
+		// The stop hangs until the forced exit.
+		Stop: func(ctx context.Context) error {
+			close(stopping)
+			select {
+			case <-exited:
+			case <-ctx.Done():
+			}
+			return nil
+		},
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- m.Run(context.Background()) }()
+
+	<-serving
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	<-stopping
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second signal didn't force the exit")
+	}
+	if exitCode != 1 {
+		t.Errorf("exit code = %d, want 1", exitCode)
+	}
+	<-done
+}
@@ -0,0 +1,156 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	ogenMiddleware "github.com/ogen-go/ogen/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New creates a logger writing to w. level is one of debug, info, warn or error and
+// format is either json or text.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be 'json' or 'text'", format)
+	}
+	return slog.New(&ContextHandler{Handler: h}), nil
+}
+
+// requestInfo is shared by every context derived from the incoming request, so fields
+// discovered deep in the ogen server are also visible to the access log.
+type requestInfo struct {
+	mu          sync.Mutex
+	operationID string
+	userID      string
+	traceID     string
+	spanID      string
+}
+
+type requestInfoKey struct{}
+
+func infoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// SetUserID records the authenticated user making the current request.
+func SetUserID(ctx context.Context, userID string) {
+	if info := infoFromContext(ctx); info != nil {
+		info.mu.Lock()
+		info.userID = userID
+		info.mu.Unlock()
+	}
+}
+
+// ContextHandler adds request ID, trace/span ID, operation ID and user ID from the context
+// to every record.
+type ContextHandler struct {
+	slog.Handler
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		r.AddAttrs(slog.String("request_id", reqID))
+	}
+
+	var traceID, spanID, operationID, userID string
+	if info := infoFromContext(ctx); info != nil {
+		info.mu.Lock()
+		traceID, spanID, operationID, userID = info.traceID, info.spanID, info.operationID, info.userID
+		info.mu.Unlock()
+	}
+	// A remote span context only comes from the caller, prefer the local server span when known.
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && (!sc.IsRemote() || traceID == "") {
+		traceID, spanID = sc.TraceID().String(), sc.SpanID().String()
+	}
+
+	if traceID != "" {
+		r.AddAttrs(slog.String("trace_id", traceID), slog.String("span_id", spanID))
+	}
+	if operationID != "" {
+		r.AddAttrs(slog.String("operation_id", operationID))
+	}
+	if userID != "" {
+		r.AddAttrs(slog.String("user_id", userID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// RequestLogger is a chi middleware logging one line per HTTP request. It must run after
+// middleware.RequestID.
+func RequestLogger(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), requestInfoKey{}, &requestInfo{})
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			log.LogAttrs(ctx, level, "request completed",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
+}
+
+// OgenMiddleware records the operation, the active span and, when no authenticated user was
+// recorded by SetUserID and userParam is present in the operation parameters, the user ID of the
+// request.
+func OgenMiddleware(userParam string) ogenMiddleware.Middleware {
+	return func(req ogenMiddleware.Request, next ogenMiddleware.Next) (ogenMiddleware.Response, error) {
+		if info := infoFromContext(req.Context); info != nil {
+			info.mu.Lock()
+			info.operationID = req.OperationID
+			if sc := trace.SpanContextFromContext(req.Context); sc.IsValid() {
+				info.traceID, info.spanID = sc.TraceID().String(), sc.SpanID().String()
+			}
+			if v, ok := req.Params[ogenMiddleware.ParameterKey{Name: userParam, In: "path"}]; ok && info.userID == "" {
+				info.userID = fmt.Sprint(v)
+			}
+			info.mu.Unlock()
+		}
+		return next(req)
+	}
+}
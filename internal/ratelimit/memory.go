@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the minimum time between two sweeps of the idle buckets.
+const sweepInterval = time.Minute
+
+// Memory is a Backend keeping the buckets in process memory, the limits are per instance.
+type Memory struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (m *Memory) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if ok {
+		b.tokens = b.refilled(now)
+		b.last, b.limit = now, limit
+	} else {
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		m.buckets[key] = b
+	}
+
+	res := Result{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = limit.refillTime(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = limit.refillTime(float64(limit.Burst) - b.tokens)
+	return res, nil
+}
+
+func (b *bucket) refilled(now time.Time) float64 {
+	return math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+}
+
+// sweep drops the buckets refilled to their burst, they are the same as missing ones.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if b.refilled(now) >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
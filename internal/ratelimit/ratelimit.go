@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opplieam/dist-mono/internal/apperr"
+	"github.com/opplieam/dist-mono/internal/auth"
+)
+
+var ErrLimited = apperr.New(apperr.KindRateLimited, "rate limit exceeded")
+
+// Limit is a token bucket refilled with Rate tokens per second up to Burst tokens, a request
+// takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// refillTime returns the time the bucket takes to refill tokens.
+func (l Limit) refillTime(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// Result is the state of a bucket after a request took a token from it.
+type Result struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// RetryAfter is the time until the next token when the request isn't allowed.
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+}
+
+// Backend stores the token buckets, so they can be shared between instances.
+type Backend interface {
+	// Take takes a token from the bucket of key, created full with limit when missing.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+type Config struct {
+	// Default applies to the operations missing from Operations.
+	Default Limit
+	// Operations holds the limits by ogen operation name, a zero rate disables the limit.
+	Operations map[string]Limit
+}
+
+// KeyFunc returns the client a request is counted against.
+type KeyFunc func(r *http.Request) string
+
+// Limiter rate limits the requests of each client, with a bucket per client and operation.
+type Limiter struct {
+	backend Backend
+	cfg     Config
+	key     KeyFunc
+	log     *slog.Logger
+}
+
+func New(backend Backend, cfg Config, key KeyFunc, log *slog.Logger) *Limiter {
+	return &Limiter{backend: backend, cfg: cfg, key: key, log: log}
+}
+
+func (l *Limiter) limit(operation string) Limit {
+	if limit, ok := l.cfg.Operations[operation]; ok {
+		return limit
+	}
+	return l.cfg.Default
+}
+
+// Middleware limits the requests by client and by the operation returned by operation. The
+// RateLimit-* headers are set on every response, and the limited requests are answered by reject
+// with ErrLimited and a Retry-After header. When the backend fails the requests are let through.
+func (l *Limiter) Middleware(operation func(r *http.Request) string, reject func(w http.ResponseWriter, r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := operation(r)
+			limit := l.limit(op)
+			if limit.Rate <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := l.backend.Take(r.Context(), l.key(r)+"|"+op, limit)
+			if err != nil {
+				l.log.ErrorContext(r.Context(), "Rate limit backend failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", seconds(res.Reset))
+			if !res.Allowed {
+				h.Set("Retry-After", seconds(res.RetryAfter))
+				reject(w, r, ErrLimited)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds formats d as whole seconds, rounded up so clients don't retry too early.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// ClientKey identifies the client by the API key in apiKeyHeader when set, else by the subject
+// of a valid bearer token, else by the remote IP. The API keys aren't checked here, so the header
+// must only be trusted behind a gateway validating them. The requests should go through the
+// verifier's Authenticate middleware first, so their token is only verified once.
+func ClientKey(apiKeyHeader string, verifier *auth.Verifier) KeyFunc {
+	return func(r *http.Request) string {
+		if apiKeyHeader != "" {
+			if key := r.Header.Get(apiKeyHeader); key != "" {
+				// Don't keep the keys themselves in the backend.
+				sum := sha256.Sum256([]byte(key))
+				return "key:" + hex.EncodeToString(sum[:])
+			}
+		}
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			// An invalid token is counted against the IP, so forged subjects don't bypass it.
+			if claims, err := verifier.VerifyContext(r.Context(), token); err == nil && claims.Subject != "" {
+				return "sub:" + claims.Subject
+			}
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "ip:" + host
+	}
+}
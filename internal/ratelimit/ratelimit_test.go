@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryTake(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for i, want := range []int{2, 1, 0} {
+		res, _ := m.Take(context.Background(), "a", limit)
+		if !res.Allowed || res.Remaining != want {
+			t.Fatalf("take %d = %+v, want allowed with %d remaining", i, res, want)
+		}
+	}
+	res, _ := m.Take(context.Background(), "a", limit)
+	if res.Allowed || res.RetryAfter != 500*time.Millisecond || res.Reset != 1500*time.Millisecond {
+		t.Fatalf("take on empty bucket = %+v", res)
+	}
+	if res, _ = m.Take(context.Background(), "b", limit); !res.Allowed {
+		t.Fatal("buckets of other keys must be independent")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if res, _ = m.Take(context.Background(), "a", limit); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("take after refill = %+v", res)
+	}
+
+	// Refilled buckets are swept, they behave as new ones.
+	now = now.Add(sweepInterval)
+	_, _ = m.Take(context.Background(), "c", limit)
+	if len(m.buckets) != 1 {
+		t.Fatalf("%d buckets left after sweep, want 1", len(m.buckets))
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l := New(NewMemory(), Config{
+		Default:    Limit{Rate: 1, Burst: 1},
+		Operations: map[string]Limit{"Unlimited": {}},
+	}, func(r *http.Request) string { return r.Header.Get("X-Client") }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var rejected error
+	handler := l.Middleware(
+		func(r *http.Request) string { return r.URL.Path[1:] },
+		func(w http.ResponseWriter, _ *http.Request, err error) {
+			rejected = err
+			w.WriteHeader(http.StatusTooManyRequests)
+		},
+	)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+	do := func(path, client string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("X-Client", client)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("/GetUser", "a")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("first request: %d %v", w.Code, w.Header())
+	}
+	w = do("/GetUser", "a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" || !errors.Is(rejected, ErrLimited) {
+		t.Fatalf("second request: %d %v %v", w.Code, w.Header(), rejected)
+	}
+	if w = do("/GetUser", "b"); w.Code != http.StatusOK {
+		t.Fatalf("other client: %d", w.Code)
+	}
+	if w = do("/ListUsers", "a"); w.Code != http.StatusOK {
+		t.Fatalf("other operation: %d", w.Code)
+	}
+	for range 3 {
+		if w = do("/Unlimited", "a"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("unlimited operation: %d %v", w.Code, w.Header())
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a consecutive-failure circuit breaker. Once open it rejects calls for openTimeout,
+// then lets up to halfOpenProbes calls through; a successful probe closes it again and a failed
+// one re-opens it. Only the outcome of the calls admitted in the current state counts, so a slow
+// call admitted while closed doesn't settle the half-open state.
+type Breaker struct {
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenProbes   int
+	onStateChange    func(from, to State)
+	now              func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	inFlight int
+	// generation changes with the state, it tells the tickets of the current state apart.
+	generation uint64
+}
+
+// Ticket is a call admitted by Allow.
+type Ticket struct {
+	generation uint64
+	probe      bool
+}
+
+func NewBreaker(failureThreshold int, openTimeout time.Duration, halfOpenProbes int, onStateChange func(from, to State)) *Breaker {
+	if halfOpenProbes < 1 {
+		halfOpenProbes = 1
+	}
+	return &Breaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		halfOpenProbes:   halfOpenProbes,
+		onStateChange:    onStateChange,
+		now:              time.Now,
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be followed by Done or
+// Release with its ticket.
+func (b *Breaker) Allow() (Ticket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return Ticket{}, ErrCircuitOpen
+		}
+		b.setState(StateHalfOpen)
+	}
+	if b.state == StateHalfOpen {
+		if b.inFlight >= b.halfOpenProbes {
+			return Ticket{}, ErrCircuitOpen
+		}
+		b.inFlight++
+		return Ticket{generation: b.generation, probe: true}, nil
+	}
+	return Ticket{generation: b.generation}, nil
+}
+
+// Done records the outcome of the call of t. It is ignored once the breaker changed state since
+// the call was admitted.
+func (b *Breaker) Done(t Ticket, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t.generation != b.generation {
+		return
+	}
+	switch b.state {
+	case StateHalfOpen:
+		b.inFlight--
+		if success {
+			b.failures = 0
+			b.setState(StateClosed)
+		} else {
+			b.open()
+		}
+	case StateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.open()
+		}
+	}
+}
+
+// Release ends the call of t without recording an outcome, for the calls abandoned by their
+// caller, which say nothing about the remote service.
+func (b *Breaker) Release(t Ticket) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t.probe && t.generation == b.generation {
+		b.inFlight--
+	}
+}
+
+func (b *Breaker) open() {
+	b.openedAt = b.now()
+	b.inFlight = 0
+	b.setState(StateOpen)
+}
+
+func (b *Breaker) setState(to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.generation++
+	if b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
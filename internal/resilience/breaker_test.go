@@ -0,0 +1,168 @@
+package resilience
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for the breaker.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(clock *fakeClock, transitions *[]string) *Breaker {
+	b := NewBreaker(2, time.Minute, 2, func(from, to State) {
+		*transitions = append(*transitions, from.String()+">"+to.String())
+	})
+	b.now = clock.Now
+	return b
+}
+
+// step is a breaker call: wait advances the clock, then Allow is called and, when it lets the
+// call through and done is set, Done with success.
+type step struct {
+	wait      time.Duration
+	allowed   bool
+	done      bool
+	success   bool
+	wantState State
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name            string
+		steps           []step
+		wantTransitions []string
+	}{
+		{
+			name: "success resets the failures",
+			steps: []step{
+				{allowed: true, done: true, success: false, wantState: StateClosed},
+				{allowed: true, done: true, success: true, wantState: StateClosed},
+				{allowed: true, done: true, success: false, wantState: StateClosed},
+			},
+		},
+		{
+			name: "consecutive failures open it",
+			steps: []step{
+				{allowed: true, done: true, success: false, wantState: StateClosed},
+				{allowed: true, done: true, success: false, wantState: StateOpen},
+				{allowed: false, wantState: StateOpen},
+				{wait: 59 * time.Second, allowed: false, wantState: StateOpen},
+			},
+			wantTransitions: []string{"closed>open"},
+		},
+		{
+			name: "successful probe closes it",
+			steps: []step{
+				{allowed: true, done: true, success: false, wantState: StateClosed},
+				{allowed: true, done: true, success: false, wantState: StateOpen},
+				{wait: time.Minute, allowed: true, done: true, success: true, wantState: StateClosed},
+				{allowed: true, done: true, success: true, wantState: StateClosed},
+			},
+			wantTransitions: []string{"closed>open", "open>half_open", "half_open>closed"},
+		},
+		{
+			name: "failed probe opens it again",
+			steps: []step{
+				{allowed: true, done: true, success: false, wantState: StateClosed},
+				{allowed: true, done: true, success: false, wantState: StateOpen},
+				{wait: time.Minute, allowed: true, done: true, success: false, wantState: StateOpen},
+				{wait: 30 * time.Second, allowed: false, wantState: StateOpen},
+			},
+			wantTransitions: []string{"closed>open", "open>half_open", "half_open>open"},
+		},
+		{
+			name: "probes are limited while half-open",
+			steps: []step{
+				{allowed: true, done: true, success: false, wantState: StateClosed},
+				{allowed: true, done: true, success: false, wantState: StateOpen},
+				{wait: time.Minute, allowed: true, wantState: StateHalfOpen},
+				{allowed: true, wantState: StateHalfOpen},
+				{allowed: false, wantState: StateHalfOpen},
+			},
+			wantTransitions: []string{"closed>open", "open>half_open"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			var transitions []string
+			b := newTestBreaker(clock, &transitions)
+
+			for i, s := range tt.steps {
+				clock.now = clock.now.Add(s.wait)
+				ticket, err := b.Allow()
+				if allowed := err == nil; allowed != s.allowed {
+					t.Fatalf("step %d: allowed = %v, want %v", i, allowed, s.allowed)
+				}
+				if err != nil && !errors.Is(err, ErrCircuitOpen) {
+					t.Fatalf("step %d: error = %v, want ErrCircuitOpen", i, err)
+				}
+				if err == nil && s.done {
+					b.Done(ticket, s.success)
+				}
+				if got := b.State(); got != s.wantState {
+					t.Fatalf("step %d: state = %v, want %v", i, got, s.wantState)
+				}
+			}
+			if !slices.Equal(transitions, tt.wantTransitions) {
+				t.Errorf("transitions = %v, want %v", transitions, tt.wantTransitions)
+			}
+		})
+	}
+}
+
+func TestBreakerStaleCalls(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var transitions []string
+	b := newTestBreaker(clock, &transitions)
+
+	// A slow call admitted while closed, which finishes once the breaker is half-open.
+	slow, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		ticket, _ := b.Allow()
+		b.Done(ticket, false)
+	}
+	clock.now = clock.now.Add(time.Minute)
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+
+	b.Done(slow, true)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("slow closed call settled the half-open breaker, state %v", b.State())
+	}
+	// The probe slots are untouched: one is left.
+	second, err := b.Allow()
+	if err != nil {
+		t.Fatalf("second probe: %v", err)
+	}
+	if _, err = b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("third probe: error = %v, want ErrCircuitOpen", err)
+	}
+
+	// A released probe frees its slot without settling anything.
+	b.Release(second)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("released probe changed the state to %v", b.State())
+	}
+	if _, err = b.Allow(); err != nil {
+		t.Fatalf("probe after the release: %v", err)
+	}
+
+	b.Done(probe, true)
+	if b.State() != StateClosed {
+		t.Errorf("successful probe: state = %v, want closed", b.State())
+	}
+}
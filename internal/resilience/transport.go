@@ -0,0 +1,203 @@
+package resilience
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type Config struct {
+	// Timeout bounds the whole call including retries, AttemptTimeout a single attempt.
+	Timeout        time.Duration
+	AttemptTimeout time.Duration
+
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	BreakerFailureThreshold int
+	BreakerOpenTimeout      time.Duration
+	BreakerHalfOpenProbes   int
+
+	// MeterProvider records the metrics, the global provider is used when it is nil.
+	MeterProvider metric.MeterProvider
+}
+
+// Transport is an http.RoundTripper adding deadlines, retries with jittered exponential backoff
+// and a circuit breaker around a base transport. Only idempotent requests are retried, and only
+// on connection errors and 5xx responses.
+type Transport struct {
+	base    http.RoundTripper
+	cfg     Config
+	breaker *Breaker
+	attrs   metric.MeasurementOption
+
+	retries metric.Int64Counter
+}
+
+// NewTransport wraps base. name identifies the remote service in the metrics.
+func NewTransport(name string, base http.RoundTripper, cfg Config) (*Transport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	meterProvider := cfg.MeterProvider
+	if meterProvider == nil {
+		meterProvider = otel.GetMeterProvider()
+	}
+	meter := meterProvider.Meter("resilience")
+	attrs := metric.WithAttributes(attribute.String("client", name))
+
+	retries, err := meter.Int64Counter("client.retries", metric.WithDescription("Retried client requests"))
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := meter.Int64Counter("client.circuit_breaker.transitions", metric.WithDescription("Circuit breaker state changes"))
+	if err != nil {
+		return nil, err
+	}
+	state, err := meter.Int64ObservableGauge("client.circuit_breaker.state",
+		metric.WithDescription("Circuit breaker state (0 closed, 1 half-open, 2 open)"))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Transport{
+		base:    base,
+		cfg:     cfg,
+		attrs:   attrs,
+		retries: retries,
+	}
+	t.breaker = NewBreaker(cfg.BreakerFailureThreshold, cfg.BreakerOpenTimeout, cfg.BreakerHalfOpenProbes, func(from, to State) {
+		transitions.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("client", name),
+			attribute.String("from", from.String()),
+			attribute.String("to", to.String()),
+		))
+	})
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		o.ObserveInt64(state, int64(t.breaker.State()), metric.WithAttributes(attribute.String("client", name)))
+		return nil
+	}, state)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Transport) Breaker() *Breaker {
+	return t.breaker
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	cancel := context.CancelFunc(func() {})
+	if t.cfg.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.cfg.Timeout)
+	}
+
+	retryable := isIdempotent(r.Method) && (r.Body == nil || r.Body == http.NoBody || r.GetBody != nil)
+	for attempt := 0; ; attempt++ {
+		ticket, err := t.breaker.Allow()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		resp, attemptCancel, err := t.attempt(ctx, r)
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		if r.Context().Err() != nil {
+			// The caller gave up, the attempt says nothing about the remote service.
+			t.breaker.Release(ticket)
+		} else {
+			t.breaker.Done(ticket, !failed)
+		}
+
+		if !failed || !retryable || attempt >= t.cfg.MaxRetries || ctx.Err() != nil {
+			if err != nil {
+				attemptCancel()
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: func() {
+				attemptCancel()
+				cancel()
+			}}
+			return resp, nil
+		}
+
+		reason := "connection_error"
+		if err == nil {
+			reason = "server_error"
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		attemptCancel()
+		t.retries.Add(ctx, 1, t.attrs, metric.WithAttributes(attribute.String("reason", reason)))
+
+		select {
+		case <-time.After(t.backoff(attempt)):
+		case <-ctx.Done():
+			cancel()
+			if err == nil {
+				err = ctx.Err()
+			}
+			return nil, err
+		}
+	}
+}
+
+func (t *Transport) attempt(ctx context.Context, r *http.Request) (*http.Response, context.CancelFunc, error) {
+	cancel := context.CancelFunc(func() {})
+	if t.cfg.AttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.cfg.AttemptTimeout)
+	}
+	req := r.Clone(ctx)
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, cancel, err
+		}
+		req.Body = body
+	}
+	resp, err := t.base.RoundTrip(req)
+	return resp, cancel, err
+}
+
+// backoff returns a full-jitter exponential delay for the given attempt.
+func (t *Transport) backoff(attempt int) time.Duration {
+	d := t.cfg.RetryBaseDelay << attempt
+	if d <= 0 || d > t.cfg.RetryMaxDelay {
+		d = t.cfg.RetryMaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// cancelBody releases the request contexts once the caller is done with the response body.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
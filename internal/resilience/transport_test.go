@@ -0,0 +1,208 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is a fake base transport.
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func response(status int) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("body"))}
+}
+
+var errConnRefused = errors.New("connection refused")
+
+func newTestTransport(t *testing.T, cfg Config, base roundTripFunc) *Transport {
+	t.Helper()
+	if cfg.BreakerFailureThreshold == 0 {
+		cfg.BreakerFailureThreshold = 100
+	}
+	if cfg.BreakerOpenTimeout == 0 {
+		cfg.BreakerOpenTimeout = time.Minute
+	}
+	tr, err := NewTransport("test", base, cfg)
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	return tr
+}
+
+func TestTransportRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		results      []any // status codes or errors, the last one repeats
+		wantAttempts int
+		wantStatus   int
+		wantErr      error
+	}{
+		{name: "success", method: http.MethodGet, results: []any{200}, wantAttempts: 1, wantStatus: 200},
+		{name: "5xx then success", method: http.MethodGet, results: []any{503, 502, 200}, wantAttempts: 3, wantStatus: 200},
+		{name: "connection error then success", method: http.MethodGet, results: []any{errConnRefused, 200}, wantAttempts: 2, wantStatus: 200},
+		{name: "retries exhausted", method: http.MethodGet, results: []any{503}, wantAttempts: 3, wantStatus: 503},
+		{name: "retries exhausted on errors", method: http.MethodGet, results: []any{errConnRefused}, wantAttempts: 3, wantErr: errConnRefused},
+		{name: "no retry on 4xx", method: http.MethodGet, results: []any{404}, wantAttempts: 1, wantStatus: 404},
+		{name: "no retry on 429", method: http.MethodGet, results: []any{429}, wantAttempts: 1, wantStatus: 429},
+		{name: "no retry of POST", method: http.MethodPost, body: `{}`, results: []any{503}, wantAttempts: 1, wantStatus: 503},
+		{name: "no retry of POST errors", method: http.MethodPost, body: `{}`, results: []any{errConnRefused}, wantAttempts: 1, wantErr: errConnRefused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			tr := newTestTransport(t, Config{MaxRetries: 2}, func(r *http.Request) (*http.Response, error) {
+				res := tt.results[min(attempts, len(tt.results)-1)]
+				attempts++
+				if err, ok := res.(error); ok {
+					return nil, err
+				}
+				return response(res.(int)), nil
+			})
+
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			r, err := http.NewRequest(tt.method, "http://category/v1/categories", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := tr.RoundTrip(r)
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTransportBreaker(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var attempts int
+	status := http.StatusServiceUnavailable
+	tr := newTestTransport(t, Config{BreakerFailureThreshold: 2, BreakerOpenTimeout: time.Minute, BreakerHalfOpenProbes: 1},
+		func(*http.Request) (*http.Response, error) {
+			attempts++
+			return response(status), nil
+		})
+	tr.Breaker().now = clock.Now
+
+	get := func() error {
+		r, _ := http.NewRequest(http.MethodGet, "http://category/v1/categories", nil)
+		resp, err := tr.RoundTrip(r)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	for range 2 {
+		if err := get(); err != nil {
+			t.Fatalf("call before opening: %v", err)
+		}
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) || attempts != 2 {
+		t.Fatalf("open breaker: error = %v after %d attempts, want ErrCircuitOpen without a call", err, attempts)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	status = http.StatusOK
+	if err := get(); err != nil || tr.Breaker().State() != StateClosed {
+		t.Fatalf("probe: error = %v, state %v, want a closed breaker", err, tr.Breaker().State())
+	}
+}
+
+func TestTransportDeadlines(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          Config
+		wantAttempts int
+		maxElapsed   time.Duration
+	}{
+		{
+			name:         "each attempt has its own deadline",
+			cfg:          Config{AttemptTimeout: 20 * time.Millisecond, Timeout: 5 * time.Second, MaxRetries: 2},
+			wantAttempts: 3,
+			maxElapsed:   time.Second,
+		},
+		{
+			name:         "overall deadline stops the retries",
+			cfg:          Config{AttemptTimeout: 5 * time.Second, Timeout: 50 * time.Millisecond, MaxRetries: 5},
+			wantAttempts: 1,
+			maxElapsed:   time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			var deadlines []time.Time
+			tr := newTestTransport(t, tt.cfg, func(r *http.Request) (*http.Response, error) {
+				attempts++
+				deadline, _ := r.Context().Deadline()
+				deadlines = append(deadlines, deadline)
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			})
+
+			r, _ := http.NewRequest(http.MethodGet, "http://category/v1/categories", nil)
+			start := time.Now()
+			_, err := tr.RoundTrip(r)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("error = %v, want a deadline exceeded", err)
+			}
+			if elapsed := time.Since(start); elapsed > tt.maxElapsed {
+				t.Errorf("took %v, want less than %v", elapsed, tt.maxElapsed)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			for i := 1; i < len(deadlines); i++ {
+				if !deadlines[i].After(deadlines[i-1]) {
+					t.Errorf("attempt %d deadline %v isn't after the previous one %v", i, deadlines[i], deadlines[i-1])
+				}
+			}
+		})
+	}
+}
+
+func TestTransportCallerCancel(t *testing.T) {
+	tr := newTestTransport(t, Config{BreakerFailureThreshold: 1, MaxRetries: 2}, func(r *http.Request) (*http.Response, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	})
+
+	for range 3 {
+		ctx, cancel := context.WithCancel(context.Background())
+		r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://category/v1/categories", nil)
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if _, err := tr.RoundTrip(r); !errors.Is(err, context.Canceled) {
+			t.Fatalf("error = %v, want context.Canceled", err)
+		}
+	}
+	if got := tr.Breaker().State(); got != StateClosed {
+		t.Errorf("breaker state after canceled calls = %v, want closed", got)
+	}
+}
@@ -0,0 +1,63 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Server returns the TLS config of a server presenting the certificate in certFile and keyFile.
+// The client certificates are verified against caFile as clientAuth asks, caFile may be empty
+// when they aren't.
+func Server(certFile, keyFile, caFile string, clientAuth tls.ClientAuthType) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+	}
+	if caFile != "" {
+		if cfg.ClientCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// Client returns the TLS config of a client verifying the servers against caFile, or the system
+// roots when it is empty, and presenting the certificate in certFile and keyFile when set.
+func Client(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read tls ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("tls ca file has no PEM certificate")
+	}
+	return pool, nil
+}
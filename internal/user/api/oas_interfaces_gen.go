@@ -5,6 +5,10 @@ type CreateUserRes interface {
 	createUserRes()
 }
 
+type DeleteUserRes interface {
+	deleteUserRes()
+}
+
 type GetAllUsersRes interface {
 	getAllUsersRes()
 }
@@ -12,3 +16,11 @@ type GetAllUsersRes interface {
 type GetUserByIdRes interface {
 	getUserByIdRes()
 }
+
+type PatchUserRes interface {
+	patchUserRes()
+}
+
+type UpdateUserRes interface {
+	updateUserRes()
+}
@@ -7,6 +7,9 @@ type OperationName = string
 
 const (
 	CreateUserOperation  OperationName = "CreateUser"
+	DeleteUserOperation  OperationName = "DeleteUser"
 	GetAllUsersOperation OperationName = "GetAllUsers"
 	GetUserByIdOperation OperationName = "GetUserById"
+	PatchUserOperation   OperationName = "PatchUser"
+	UpdateUserOperation  OperationName = "UpdateUser"
 )
@@ -86,12 +86,24 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				if len(elem) == 0 {
 					// Leaf node.
 					switch r.Method {
+					case "DELETE":
+						s.handleDeleteUserRequest([1]string{
+							args[0],
+						}, elemIsEscaped, w, r)
 					case "GET":
 						s.handleGetUserByIdRequest([1]string{
 							args[0],
 						}, elemIsEscaped, w, r)
+					case "PATCH":
+						s.handlePatchUserRequest([1]string{
+							args[0],
+						}, elemIsEscaped, w, r)
+					case "PUT":
+						s.handleUpdateUserRequest([1]string{
+							args[0],
+						}, elemIsEscaped, w, r)
 					default:
-						s.notAllowed(w, r, "GET")
+						s.notAllowed(w, r, "DELETE,GET,PATCH,PUT")
 					}
 
 					return
@@ -228,6 +240,14 @@ func (s *Server) FindPath(method string, u *url.URL) (r Route, _ bool) {
 				if len(elem) == 0 {
 					// Leaf node.
 					switch method {
+					case "DELETE":
+						r.name = DeleteUserOperation
+						r.summary = "Delete a user by ID"
+						r.operationID = "deleteUser"
+						r.pathPattern = "/user/{id}"
+						r.args = args
+						r.count = 1
+						return r, true
 					case "GET":
 						r.name = GetUserByIdOperation
 						r.summary = "Get a user by ID"
@@ -236,6 +256,22 @@ func (s *Server) FindPath(method string, u *url.URL) (r Route, _ bool) {
 						r.args = args
 						r.count = 1
 						return r, true
+					case "PATCH":
+						r.name = PatchUserOperation
+						r.summary = "Partially update a user by ID"
+						r.operationID = "patchUser"
+						r.pathPattern = "/user/{id}"
+						r.args = args
+						r.count = 1
+						return r, true
+					case "PUT":
+						r.name = UpdateUserOperation
+						r.summary = "Replace a user by ID"
+						r.operationID = "updateUser"
+						r.pathPattern = "/user/{id}"
+						r.args = args
+						r.count = 1
+						return r, true
 					default:
 						return
 					}
@@ -4,12 +4,29 @@ package api
 
 import (
 	"fmt"
+	"time"
+
+	"github.com/go-faster/errors"
 )
 
 func (s *ErrorStatusCode) Error() string {
 	return fmt.Sprintf("code %d: %+v", s.StatusCode, s.Response)
 }
 
+type BearerAuth struct {
+	Token string
+}
+
+// GetToken returns the value of Token.
+func (s *BearerAuth) GetToken() string {
+	return s.Token
+}
+
+// SetToken sets the value of Token.
+func (s *BearerAuth) SetToken(val string) {
+	s.Token = val
+}
+
 type CreateUserBadRequest Error
 
 func (*CreateUserBadRequest) createUserRes() {}
@@ -18,6 +35,19 @@ type CreateUserInternalServerError Error
 
 func (*CreateUserInternalServerError) createUserRes() {}
 
+type DeleteUserBadRequest Error
+
+func (*DeleteUserBadRequest) deleteUserRes() {}
+
+type DeleteUserInternalServerError Error
+
+func (*DeleteUserInternalServerError) deleteUserRes() {}
+
+// DeleteUserNoContent is response for DeleteUser operation.
+type DeleteUserNoContent struct{}
+
+func (*DeleteUserNoContent) deleteUserRes() {}
+
 // Ref: #/components/schemas/Error
 type Error struct {
 	// The error message.
@@ -68,9 +98,112 @@ type GetAllUsersInternalServerError Error
 
 func (*GetAllUsersInternalServerError) getAllUsersRes() {}
 
-type GetAllUsersOKApplicationJSON []User
+// GetAllUsersOK represents sum type.
+type GetAllUsersOK struct {
+	Type      GetAllUsersOKType // switch on this field
+	UserArray []User
+	UserPage  UserPage
+}
+
+// GetAllUsersOKType is oneOf type of GetAllUsersOK.
+type GetAllUsersOKType string
+
+// Possible values for GetAllUsersOKType.
+const (
+	UserArrayGetAllUsersOK GetAllUsersOKType = "[]User"
+	UserPageGetAllUsersOK  GetAllUsersOKType = "UserPage"
+)
+
+// IsUserArray reports whether GetAllUsersOK is []User.
+func (s GetAllUsersOK) IsUserArray() bool { return s.Type == UserArrayGetAllUsersOK }
 
-func (*GetAllUsersOKApplicationJSON) getAllUsersRes() {}
+// IsUserPage reports whether GetAllUsersOK is UserPage.
+func (s GetAllUsersOK) IsUserPage() bool { return s.Type == UserPageGetAllUsersOK }
+
+// SetUserArray sets GetAllUsersOK to []User.
+func (s *GetAllUsersOK) SetUserArray(v []User) {
+	s.Type = UserArrayGetAllUsersOK
+	s.UserArray = v
+}
+
+// GetUserArray returns []User and true boolean if GetAllUsersOK is []User.
+func (s GetAllUsersOK) GetUserArray() (v []User, ok bool) {
+	if !s.IsUserArray() {
+		return v, false
+	}
+	return s.UserArray, true
+}
+
+// NewUserArrayGetAllUsersOK returns new GetAllUsersOK from []User.
+func NewUserArrayGetAllUsersOK(v []User) GetAllUsersOK {
+	var s GetAllUsersOK
+	s.SetUserArray(v)
+	return s
+}
+
+// SetUserPage sets GetAllUsersOK to UserPage.
+func (s *GetAllUsersOK) SetUserPage(v UserPage) {
+	s.Type = UserPageGetAllUsersOK
+	s.UserPage = v
+}
+
+// GetUserPage returns UserPage and true boolean if GetAllUsersOK is UserPage.
+func (s GetAllUsersOK) GetUserPage() (v UserPage, ok bool) {
+	if !s.IsUserPage() {
+		return v, false
+	}
+	return s.UserPage, true
+}
+
+// NewUserPageGetAllUsersOK returns new GetAllUsersOK from UserPage.
+func NewUserPageGetAllUsersOK(v UserPage) GetAllUsersOK {
+	var s GetAllUsersOK
+	s.SetUserPage(v)
+	return s
+}
+
+func (*GetAllUsersOK) getAllUsersRes() {}
+
+type GetAllUsersSort string
+
+const (
+	GetAllUsersSortAsc  GetAllUsersSort = "asc"
+	GetAllUsersSortDesc GetAllUsersSort = "desc"
+)
+
+// AllValues returns all GetAllUsersSort values.
+func (GetAllUsersSort) AllValues() []GetAllUsersSort {
+	return []GetAllUsersSort{
+		GetAllUsersSortAsc,
+		GetAllUsersSortDesc,
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s GetAllUsersSort) MarshalText() ([]byte, error) {
+	switch s {
+	case GetAllUsersSortAsc:
+		return []byte(s), nil
+	case GetAllUsersSortDesc:
+		return []byte(s), nil
+	default:
+		return nil, errors.Errorf("invalid value: %q", s)
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *GetAllUsersSort) UnmarshalText(data []byte) error {
+	switch GetAllUsersSort(data) {
+	case GetAllUsersSortAsc:
+		*s = GetAllUsersSortAsc
+		return nil
+	case GetAllUsersSortDesc:
+		*s = GetAllUsersSortDesc
+		return nil
+	default:
+		return errors.Errorf("invalid value: %q", data)
+	}
+}
 
 type GetUserByIdBadRequest Error
 
@@ -80,6 +213,206 @@ type GetUserByIdInternalServerError Error
 
 func (*GetUserByIdInternalServerError) getUserByIdRes() {}
 
+// NewOptBool returns new OptBool with value set to v.
+func NewOptBool(v bool) OptBool {
+	return OptBool{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptBool is optional bool.
+type OptBool struct {
+	Value bool
+	Set   bool
+}
+
+// IsSet returns true if OptBool was set.
+func (o OptBool) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptBool) Reset() {
+	var v bool
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptBool) SetTo(v bool) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptBool) Get() (v bool, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptBool) Or(d bool) bool {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
+// NewOptGetAllUsersSort returns new OptGetAllUsersSort with value set to v.
+func NewOptGetAllUsersSort(v GetAllUsersSort) OptGetAllUsersSort {
+	return OptGetAllUsersSort{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptGetAllUsersSort is optional GetAllUsersSort.
+type OptGetAllUsersSort struct {
+	Value GetAllUsersSort
+	Set   bool
+}
+
+// IsSet returns true if OptGetAllUsersSort was set.
+func (o OptGetAllUsersSort) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptGetAllUsersSort) Reset() {
+	var v GetAllUsersSort
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptGetAllUsersSort) SetTo(v GetAllUsersSort) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptGetAllUsersSort) Get() (v GetAllUsersSort, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptGetAllUsersSort) Or(d GetAllUsersSort) GetAllUsersSort {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
+// NewOptInt returns new OptInt with value set to v.
+func NewOptInt(v int) OptInt {
+	return OptInt{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptInt is optional int.
+type OptInt struct {
+	Value int
+	Set   bool
+}
+
+// IsSet returns true if OptInt was set.
+func (o OptInt) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptInt) Reset() {
+	var v int
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptInt) SetTo(v int) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptInt) Get() (v int, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptInt) Or(d int) int {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
+// NewOptString returns new OptString with value set to v.
+func NewOptString(v string) OptString {
+	return OptString{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptString is optional string.
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+// IsSet returns true if OptString was set.
+func (o OptString) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptString) Reset() {
+	var v string
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptString) SetTo(v string) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptString) Get() (v string, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptString) Or(d string) string {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
+type PatchUserBadRequest Error
+
+func (*PatchUserBadRequest) patchUserRes() {}
+
+type PatchUserInternalServerError Error
+
+func (*PatchUserInternalServerError) patchUserRes() {}
+
+type UpdateUserBadRequest Error
+
+func (*UpdateUserBadRequest) updateUserRes() {}
+
+type UpdateUserInternalServerError Error
+
+func (*UpdateUserInternalServerError) updateUserRes() {}
+
 // Ref: #/components/schemas/User
 type User struct {
 	// The unique identifier for the user.
@@ -88,6 +421,10 @@ type User struct {
 	Name string `json:"name"`
 	// The email address of the user.
 	Email string `json:"email"`
+	// When the user was created.
+	CreatedAt time.Time `json:"created_at"`
+	// When the user was last modified.
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // GetID returns the value of ID.
@@ -105,6 +442,16 @@ func (s *User) GetEmail() string {
 	return s.Email
 }
 
+// GetCreatedAt returns the value of CreatedAt.
+func (s *User) GetCreatedAt() time.Time {
+	return s.CreatedAt
+}
+
+// GetUpdatedAt returns the value of UpdatedAt.
+func (s *User) GetUpdatedAt() time.Time {
+	return s.UpdatedAt
+}
+
 // SetID sets the value of ID.
 func (s *User) SetID(val int) {
 	s.ID = val
@@ -120,7 +467,19 @@ func (s *User) SetEmail(val string) {
 	s.Email = val
 }
 
+// SetCreatedAt sets the value of CreatedAt.
+func (s *User) SetCreatedAt(val time.Time) {
+	s.CreatedAt = val
+}
+
+// SetUpdatedAt sets the value of UpdatedAt.
+func (s *User) SetUpdatedAt(val time.Time) {
+	s.UpdatedAt = val
+}
+
 func (*User) createUserRes() {}
+func (*User) patchUserRes()  {}
+func (*User) updateUserRes() {}
 
 // Ref: #/components/schemas/UserCategory
 type UserCategory struct {
@@ -128,8 +487,10 @@ type UserCategory struct {
 	ID int `json:"id"`
 	// The name of the user.
 	Name string `json:"name"`
-	// The name of the category.
-	Category string `json:"category"`
+	// The names of the categories owned by the user, omitted when degraded.
+	Categories []string `json:"categories"`
+	// Whether the categories could not be loaded.
+	Degraded OptBool `json:"degraded"`
 }
 
 // GetID returns the value of ID.
@@ -142,9 +503,14 @@ func (s *UserCategory) GetName() string {
 	return s.Name
 }
 
-// GetCategory returns the value of Category.
-func (s *UserCategory) GetCategory() string {
-	return s.Category
+// GetCategories returns the value of Categories.
+func (s *UserCategory) GetCategories() []string {
+	return s.Categories
+}
+
+// GetDegraded returns the value of Degraded.
+func (s *UserCategory) GetDegraded() OptBool {
+	return s.Degraded
 }
 
 // SetID sets the value of ID.
@@ -157,9 +523,124 @@ func (s *UserCategory) SetName(val string) {
 	s.Name = val
 }
 
-// SetCategory sets the value of Category.
-func (s *UserCategory) SetCategory(val string) {
-	s.Category = val
+// SetCategories sets the value of Categories.
+func (s *UserCategory) SetCategories(val []string) {
+	s.Categories = val
+}
+
+// SetDegraded sets the value of Degraded.
+func (s *UserCategory) SetDegraded(val OptBool) {
+	s.Degraded = val
 }
 
-func (*UserCategory) getUserByIdRes() {}
+// UserCategoryHeaders wraps UserCategory with response headers.
+type UserCategoryHeaders struct {
+	Warning  OptString
+	Response UserCategory
+}
+
+// GetWarning returns the value of Warning.
+func (s *UserCategoryHeaders) GetWarning() OptString {
+	return s.Warning
+}
+
+// GetResponse returns the value of Response.
+func (s *UserCategoryHeaders) GetResponse() UserCategory {
+	return s.Response
+}
+
+// SetWarning sets the value of Warning.
+func (s *UserCategoryHeaders) SetWarning(val OptString) {
+	s.Warning = val
+}
+
+// SetResponse sets the value of Response.
+func (s *UserCategoryHeaders) SetResponse(val UserCategory) {
+	s.Response = val
+}
+
+func (*UserCategoryHeaders) getUserByIdRes() {}
+
+// Ref: #/components/schemas/UserCreate
+type UserCreate struct {
+	// The name of the user.
+	Name string `json:"name"`
+	// The email address of the user.
+	Email string `json:"email"`
+}
+
+// GetName returns the value of Name.
+func (s *UserCreate) GetName() string {
+	return s.Name
+}
+
+// GetEmail returns the value of Email.
+func (s *UserCreate) GetEmail() string {
+	return s.Email
+}
+
+// SetName sets the value of Name.
+func (s *UserCreate) SetName(val string) {
+	s.Name = val
+}
+
+// SetEmail sets the value of Email.
+func (s *UserCreate) SetEmail(val string) {
+	s.Email = val
+}
+
+// Ref: #/components/schemas/UserPage
+type UserPage struct {
+	Users []User `json:"users"`
+	// Cursor for the next page, absent on the last page.
+	NextCursor OptInt `json:"next_cursor"`
+}
+
+// GetUsers returns the value of Users.
+func (s *UserPage) GetUsers() []User {
+	return s.Users
+}
+
+// GetNextCursor returns the value of NextCursor.
+func (s *UserPage) GetNextCursor() OptInt {
+	return s.NextCursor
+}
+
+// SetUsers sets the value of Users.
+func (s *UserPage) SetUsers(val []User) {
+	s.Users = val
+}
+
+// SetNextCursor sets the value of NextCursor.
+func (s *UserPage) SetNextCursor(val OptInt) {
+	s.NextCursor = val
+}
+
+// Partial update of a user, omitted fields are left unchanged.
+// Ref: #/components/schemas/UserUpdate
+type UserUpdate struct {
+	// The name of the user.
+	Name OptString `json:"name"`
+	// The email address of the user.
+	Email OptString `json:"email"`
+}
+
+// GetName returns the value of Name.
+func (s *UserUpdate) GetName() OptString {
+	return s.Name
+}
+
+// GetEmail returns the value of Email.
+func (s *UserUpdate) GetEmail() OptString {
+	return s.Email
+}
+
+// SetName sets the value of Name.
+func (s *UserUpdate) SetName(val OptString) {
+	s.Name = val
+}
+
+// SetEmail sets the value of Email.
+func (s *UserUpdate) SetEmail(val OptString) {
+	s.Email = val
+}
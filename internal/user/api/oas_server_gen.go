@@ -13,19 +13,37 @@ type Handler interface {
 	// Create a new user.
 	//
 	// POST /user
-	CreateUser(ctx context.Context, req *User) (CreateUserRes, error)
+	CreateUser(ctx context.Context, req *UserCreate, params CreateUserParams) (CreateUserRes, error)
+	// DeleteUser implements deleteUser operation.
+	//
+	// Delete a user by ID.
+	//
+	// DELETE /user/{id}
+	DeleteUser(ctx context.Context, params DeleteUserParams) (DeleteUserRes, error)
 	// GetAllUsers implements getAllUsers operation.
 	//
 	// Get all users.
 	//
 	// GET /user
-	GetAllUsers(ctx context.Context) (GetAllUsersRes, error)
+	GetAllUsers(ctx context.Context, params GetAllUsersParams) (GetAllUsersRes, error)
 	// GetUserById implements getUserById operation.
 	//
 	// Get a user by ID.
 	//
 	// GET /user/{id}
 	GetUserById(ctx context.Context, params GetUserByIdParams) (GetUserByIdRes, error)
+	// PatchUser implements patchUser operation.
+	//
+	// Partially update a user by ID.
+	//
+	// PATCH /user/{id}
+	PatchUser(ctx context.Context, req *UserUpdate, params PatchUserParams) (PatchUserRes, error)
+	// UpdateUser implements updateUser operation.
+	//
+	// Replace a user by ID.
+	//
+	// PUT /user/{id}
+	UpdateUser(ctx context.Context, req *UserCreate, params UpdateUserParams) (UpdateUserRes, error)
 	// NewError creates *ErrorStatusCode from error returned by handler.
 	//
 	// Used for common default response.
@@ -35,18 +53,20 @@ type Handler interface {
 // Server implements http server based on OpenAPI v3 specification and
 // calls Handler to handle requests.
 type Server struct {
-	h Handler
+	h   Handler
+	sec SecurityHandler
 	baseServer
 }
 
 // NewServer creates new Server.
-func NewServer(h Handler, opts ...ServerOption) (*Server, error) {
+func NewServer(h Handler, sec SecurityHandler, opts ...ServerOption) (*Server, error) {
 	s, err := newServerConfig(opts...).baseServer()
 	if err != nil {
 		return nil, err
 	}
 	return &Server{
 		h:          h,
+		sec:        sec,
 		baseServer: s,
 	}, nil
 }
@@ -18,7 +18,16 @@ var _ Handler = UnimplementedHandler{}
 // Create a new user.
 //
 // POST /user
-func (UnimplementedHandler) CreateUser(ctx context.Context, req *User) (r CreateUserRes, _ error) {
+func (UnimplementedHandler) CreateUser(ctx context.Context, req *UserCreate, params CreateUserParams) (r CreateUserRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// DeleteUser implements deleteUser operation.
+//
+// Delete a user by ID.
+//
+// DELETE /user/{id}
+func (UnimplementedHandler) DeleteUser(ctx context.Context, params DeleteUserParams) (r DeleteUserRes, _ error) {
 	return r, ht.ErrNotImplemented
 }
 
@@ -27,7 +36,7 @@ func (UnimplementedHandler) CreateUser(ctx context.Context, req *User) (r Create
 // Get all users.
 //
 // GET /user
-func (UnimplementedHandler) GetAllUsers(ctx context.Context) (r GetAllUsersRes, _ error) {
+func (UnimplementedHandler) GetAllUsers(ctx context.Context, params GetAllUsersParams) (r GetAllUsersRes, _ error) {
 	return r, ht.ErrNotImplemented
 }
 
@@ -40,6 +49,24 @@ func (UnimplementedHandler) GetUserById(ctx context.Context, params GetUserByIdP
 	return r, ht.ErrNotImplemented
 }
 
+// PatchUser implements patchUser operation.
+//
+// Partially update a user by ID.
+//
+// PATCH /user/{id}
+func (UnimplementedHandler) PatchUser(ctx context.Context, req *UserUpdate, params PatchUserParams) (r PatchUserRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// UpdateUser implements updateUser operation.
+//
+// Replace a user by ID.
+//
+// PUT /user/{id}
+func (UnimplementedHandler) UpdateUser(ctx context.Context, req *UserCreate, params UpdateUserParams) (r UpdateUserRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
 // NewError creates *ErrorStatusCode from error returned by handler.
 //
 // Used for common default response.
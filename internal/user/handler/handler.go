@@ -2,143 +2,323 @@ package handler
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ogen-go/ogen/ogenerrors"
+	"github.com/opplieam/dist-mono/internal/apperr"
+	"github.com/opplieam/dist-mono/internal/auth"
+	"github.com/opplieam/dist-mono/internal/authz"
+	"github.com/opplieam/dist-mono/internal/health"
+	"github.com/opplieam/dist-mono/internal/logger"
+	"github.com/opplieam/dist-mono/internal/ratelimit"
+	"github.com/opplieam/dist-mono/internal/telemetry"
 	"github.com/opplieam/dist-mono/internal/user/api"
 	"github.com/opplieam/dist-mono/internal/user/store"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Storer interface {
-	CreateUser(ctx context.Context, name, email string) (int, error)
-	GetAllUsers(ctx context.Context) (*api.GetAllUsersOKApplicationJSON, error)
-	GetUserCategory(ctx context.Context, userID int) (*api.UserCategory, error)
+	CreateUser(ctx context.Context, name, email string, key *store.IdempotencyKey) (*api.User, error)
+	GetAllUsers(ctx context.Context, params api.GetAllUsersParams) (*api.UserPage, error)
+	GetUserCategory(ctx context.Context, userID int, degrade bool) (*api.UserCategory, error)
+	UpdateUser(ctx context.Context, userID int, name, email string) (*api.User, error)
+	PatchUser(ctx context.Context, userID int, name, email api.OptString) (*api.User, error)
+	DeleteUser(ctx context.Context, userID int) error
+}
+
+type Config struct {
+	Addr string
+	// HealthCheckTimeout bounds each readiness check registered without its own timeout.
+	HealthCheckTimeout time.Duration
+	// TLS serves HTTPS when set.
+	TLS *tls.Config
+	// RateLimiter limits the API requests of each client, nil disables rate limiting.
+	RateLimiter *ratelimit.Limiter
+	// MeterProvider and TracerProvider record the telemetry of the service, the global providers
+	// are used when they are nil.
+	MeterProvider  metric.MeterProvider
+	TracerProvider trace.TracerProvider
+	// StrictCategory fails GetUserById when the category service fails, instead of answering
+	// without categories. Requests can override it with the strict query parameter.
+	StrictCategory bool
 }
 
 type UserHandler struct {
+	cfg        Config
 	hServer    *http.Server
+	listener   net.Listener
 	store      Storer
+	verifier   *auth.Verifier
+	policy     *authz.Policy
+	health     *health.Registry
 	errCounter metric.Int64Counter
+	log        *slog.Logger
+
+	handlerOnce sync.Once
+	handler     http.Handler
+	handlerErr  error
 }
 
-var _ api.Handler = (*UserHandler)(nil)
+var (
+	_ api.Handler         = (*UserHandler)(nil)
+	_ api.SecurityHandler = (*UserHandler)(nil)
+)
 
-func NewUserHandler(s Storer) *UserHandler {
-	meter := otel.GetMeterProvider().Meter("service-user")
+func NewUserHandler(s Storer, verifier *auth.Verifier, log *slog.Logger, cfg Config) *UserHandler {
+	meterProvider := cfg.MeterProvider
+	if meterProvider == nil {
+		meterProvider = otel.GetMeterProvider()
+	}
+	meter := meterProvider.Meter("service-user")
 	errCounter, _ := meter.Int64Counter("service.errors", metric.WithDescription("Total service errors"))
 	return &UserHandler{
+		cfg:        cfg,
 		store:      s,
+		verifier:   verifier,
+		policy:     authz.NewPolicy(log),
+		health:     health.NewRegistry(cfg.HealthCheckTimeout),
 		errCounter: errCounter,
+		log:        log,
 	}
 }
 
-func (u *UserHandler) Start() (chan os.Signal, error) {
-	srv, err := api.NewServer(u)
+// Health returns the registry behind the probe endpoints, checks must be registered before Start.
+func (u *UserHandler) Health() *health.Registry {
+	return u.health
+}
+
+// Handler returns the HTTP handler of the service, the API under /v1 and the probes. It is built
+// once, so it can also be called in-process.
+func (u *UserHandler) Handler() (http.Handler, error) {
+	u.handlerOnce.Do(func() {
+		srv, err := api.NewServer(u, u,
+			api.WithErrorHandler(u.handleError),
+			api.WithMeterProvider(u.cfg.MeterProvider),
+			api.WithTracerProvider(u.cfg.TracerProvider),
+			api.WithMiddleware(logger.OgenMiddleware("id")),
+		)
+		if err != nil {
+			u.handlerErr = fmt.Errorf("failed to create server: %w", err)
+			return
+		}
+		r := chi.NewRouter()
+		r.Use(middleware.RequestID)
+		r.Use(telemetry.ExtractTraceContext)
+		r.Use(logger.RequestLogger(u.log))
+		r.Use(middleware.Recoverer)
+
+		u.health.Mount(r)
+		r.Mount("/v1", http.StripPrefix("/v1", u.rateLimit(srv)))
+		u.handler = r
+	})
+	return u.handler, u.handlerErr
+}
+
+// Start binds the listen address, so a bad address fails the startup instead of a background
+// goroutine. Requests are only served once Serve is called.
+func (u *UserHandler) Start() error {
+	handler, err := u.Handler()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create server: %w", err)
+		return err
 	}
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-
-	r.Mount("/v1", http.StripPrefix("/v1", srv))
 	u.hServer = &http.Server{
-		Addr:    ":3000",
-		Handler: r,
+		Addr:    u.cfg.Addr,
+		Handler: handler,
 	}
 
-	go func() {
-		log.Printf("User service listening on %s", u.hServer.Addr)
-		if hErr := u.hServer.ListenAndServe(); !errors.Is(hErr, http.ErrServerClosed) {
-			log.Fatalf("Failed to listen on %s: %v", u.hServer.Addr, hErr)
-		}
-	}()
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	u.listener, err = net.Listen("tcp", u.hServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", u.hServer.Addr, err)
+	}
+	if u.cfg.TLS != nil {
+		u.listener = tls.NewListener(u.listener, u.cfg.TLS)
+	}
+	u.health.MarkStarted()
+	return nil
+}
 
-	return sigChan, nil
+// Serve serves requests until Shutdown, it returns nil once the server is shut down.
+func (u *UserHandler) Serve() error {
+	u.log.Info("User service listening", "addr", u.listener.Addr().String(), "tls", u.cfg.TLS != nil)
+	if err := u.hServer.Serve(u.listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
-func (u *UserHandler) Shutdown() error {
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Drain fails the readiness probe, so load balancers stop routing new requests here.
+func (u *UserHandler) Drain() {
+	u.health.MarkShuttingDown()
+}
 
-	return u.hServer.Shutdown(shutdownCtx)
+// Shutdown stops accepting connections and waits for in-flight requests until ctx is done.
+func (u *UserHandler) Shutdown(ctx context.Context) error {
+	u.health.MarkShuttingDown()
+	return u.hServer.Shutdown(ctx)
 }
 
-func (u *UserHandler) CreateUser(ctx context.Context, req *api.User) (api.CreateUserRes, error) {
-	name := req.GetName()
-	email := req.GetEmail()
-	userId, err := u.store.CreateUser(ctx, name, email)
+func (u *UserHandler) CreateUser(ctx context.Context, req *api.UserCreate, params api.CreateUserParams) (api.CreateUserRes, error) {
+	if err := u.policy.RequireRole(ctx, string(api.CreateUserOperation), authz.RoleAdmin); err != nil {
+		return nil, err
+	}
+	var key *store.IdempotencyKey
+	if k, ok := params.IdempotencyKey.Get(); ok {
+		// Keys are scoped to the client, so clients can't replay each other's responses.
+		key = &store.IdempotencyKey{Key: k}
+		if claims, ok := auth.ClaimsFromContext(ctx); ok {
+			key.Client = claims.Subject
+		}
+	}
+	user, err := u.store.CreateUser(ctx, req.GetName(), req.GetEmail(), key)
 	if err != nil {
 		return nil, err
 	}
-	return &api.User{
-		ID:    userId,
-		Name:  name,
-		Email: email,
-	}, nil
+	return user, nil
 }
 
-func (u *UserHandler) GetAllUsers(ctx context.Context) (api.GetAllUsersRes, error) {
-	users, err := u.store.GetAllUsers(ctx)
+// GetAllUsers answers with a UserPage when the request paginates, and with the bare array of users
+// the clients predating the pagination expect otherwise.
+func (u *UserHandler) GetAllUsers(ctx context.Context, params api.GetAllUsersParams) (api.GetAllUsersRes, error) {
+	if err := u.policy.RequireRole(ctx, string(api.GetAllUsersOperation), authz.RoleAdmin); err != nil {
+		return nil, err
+	}
+	page, err := u.store.GetAllUsers(ctx, params)
 	if err != nil {
 		return nil, err
 	}
-	return users, nil
+	if params.Limit.IsSet() || params.Cursor.IsSet() {
+		res := api.NewUserPageGetAllUsersOK(*page)
+		return &res, nil
+	}
+	res := api.NewUserArrayGetAllUsersOK(page.Users)
+	return &res, nil
 }
 
 func (u *UserHandler) GetUserById(ctx context.Context, params api.GetUserByIdParams) (api.GetUserByIdRes, error) {
-	userCat, err := u.store.GetUserCategory(ctx, params.ID)
+	if err := u.policy.RequireOwner(ctx, string(api.GetUserByIdOperation), params.ID); err != nil {
+		return nil, err
+	}
+	strict := params.Strict.Or(u.cfg.StrictCategory)
+	userCat, err := u.store.GetUserCategory(ctx, params.ID, !strict)
+	if err != nil {
+		return nil, err
+	}
+	res := &api.UserCategoryHeaders{Response: *userCat}
+	if userCat.Degraded.Value {
+		u.errCounter.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("type", "degraded"),
+		))
+		res.Warning = api.NewOptString(`199 user-service "category service unavailable, categories omitted"`)
+	}
+	return res, nil
+}
+
+func (u *UserHandler) UpdateUser(ctx context.Context, req *api.UserCreate, params api.UpdateUserParams) (api.UpdateUserRes, error) {
+	if err := u.policy.RequireOwner(ctx, string(api.UpdateUserOperation), params.ID); err != nil {
+		return nil, err
+	}
+	user, err := u.store.UpdateUser(ctx, params.ID, req.GetName(), req.GetEmail())
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *UserHandler) PatchUser(ctx context.Context, req *api.UserUpdate, params api.PatchUserParams) (api.PatchUserRes, error) {
+	if err := u.policy.RequireOwner(ctx, string(api.PatchUserOperation), params.ID); err != nil {
+		return nil, err
+	}
+	user, err := u.store.PatchUser(ctx, params.ID, req.GetName(), req.GetEmail())
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *UserHandler) DeleteUser(ctx context.Context, params api.DeleteUserParams) (api.DeleteUserRes, error) {
+	if err := u.policy.RequireRole(ctx, string(api.DeleteUserOperation), authz.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := u.store.DeleteUser(ctx, params.ID); err != nil {
+		return nil, err
+	}
+	return &api.DeleteUserNoContent{}, nil
+}
+
+// HandleBearerAuth verifies the bearer token, puts its claims in the context and records the
+// user for the logs.
+func (u *UserHandler) HandleBearerAuth(ctx context.Context, _ api.OperationName, t api.BearerAuth) (context.Context, error) {
+	claims, err := u.verifier.VerifyContext(ctx, t.Token)
 	if err != nil {
 		return nil, err
 	}
-	return userCat, nil
+	logger.SetUserID(ctx, claims.Subject)
+	return auth.WithClaims(ctx, claims, t.Token), nil
+}
+
+// rateLimit limits the requests served by srv per client and operation when a rate limiter is
+// configured. The bearer token is verified before, to identify the client, and HandleBearerAuth
+// reuses the outcome.
+func (u *UserHandler) rateLimit(srv *api.Server) http.Handler {
+	if u.cfg.RateLimiter == nil {
+		return srv
+	}
+	operation := func(r *http.Request) string {
+		route, _ := srv.FindPath(r.Method, r.URL)
+		return route.Name()
+	}
+	reject := func(w http.ResponseWriter, r *http.Request, err error) {
+		u.handleError(r.Context(), w, r, err)
+	}
+	return u.verifier.Authenticate(u.cfg.RateLimiter.Middleware(operation, reject)(srv))
+}
+
+// handleError reports the request decoding errors through NewError, so every error response
+// has the same shape.
+func (u *UserHandler) handleError(ctx context.Context, w http.ResponseWriter, _ *http.Request, err error) {
+	if code := ogenerrors.ErrorCode(err); code >= http.StatusBadRequest && code < http.StatusInternalServerError {
+		err = &apperr.Error{Kind: apperr.KindInvalid, Message: err.Error(), Err: err}
+	}
+	res := u.NewError(ctx, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(res.StatusCode)
+	_ = json.NewEncoder(w).Encode(res.Response)
 }
 
 func (u *UserHandler) NewError(ctx context.Context, err error) *api.ErrorStatusCode {
-	switch {
-	case errors.Is(err, store.ErrUserNotFound):
-		u.errCounter.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("type", "not_found"),
-		))
-		return &api.ErrorStatusCode{
-			StatusCode: http.StatusNotFound,
-			Response: api.Error{
-				Message: err.Error(),
-			},
-		}
-	case errors.Is(err, store.ErrCategoryConn):
-		u.errCounter.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("type", "dependency_failure"),
-		))
-		return &api.ErrorStatusCode{
-			StatusCode: http.StatusServiceUnavailable,
-			Response: api.Error{
-				Message: err.Error(),
-			},
-		}
-	default:
-		u.errCounter.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("type", "internal"),
-		))
-		return &api.ErrorStatusCode{
-			StatusCode: http.StatusInternalServerError,
-			Response: api.Error{
-				Message: err.Error(),
-			},
-		}
+	var secErr *ogenerrors.SecurityError
+	if errors.As(err, &secErr) && apperr.KindOf(err) == apperr.KindInternal {
+		// No or malformed Authorization header.
+		err = auth.ErrUnauthenticated.Wrap(err)
+	}
+	appErr := apperr.Translate(err)
+	// Only the public message goes to the client, the full error stays in logs and spans.
+	trace.SpanFromContext(ctx).RecordError(err)
+	level := slog.LevelWarn
+	if appErr.Kind == apperr.KindInternal {
+		level = slog.LevelError
+	}
+	u.log.Log(ctx, level, "Request failed", "error", err, "type", appErr.Kind.String())
+	u.errCounter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("type", appErr.Kind.String()),
+	))
+	return &api.ErrorStatusCode{
+		StatusCode: appErr.Kind.StatusCode(),
+		Response: api.Error{
+			Message: appErr.Message,
+		},
 	}
 }
@@ -2,88 +2,455 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	db "github.com/opplieam/dist-mono/db/sqlc"
+	"github.com/opplieam/dist-mono/internal/apperr"
 	catApi "github.com/opplieam/dist-mono/internal/category/api"
 	"github.com/opplieam/dist-mono/internal/user/api"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
-	ErrUserNotFound    = errors.New("user not found")
-	ErrCategoryConn    = errors.New("category service down")
-	ErrNoCategoryFound = errors.New("no category found for this user")
+	ErrUserNotFound         = apperr.New(apperr.KindNotFound, "user not found")
+	ErrEmailTaken           = apperr.New(apperr.KindConflict, "email already in use")
+	ErrCategoryConn         = apperr.New(apperr.KindUnavailable, "category service down")
+	ErrNoCategoryFound      = apperr.New(apperr.KindUnavailable, "no category found for this user")
+	ErrIdempotencyKeyReused = apperr.New(apperr.KindUnprocessable, "idempotency key already used with a different request")
+	// ErrCategoryRejected is a category service 4xx, a bug or misconfiguration on this side that
+	// waiting won't fix, so it isn't degraded.
+	ErrCategoryRejected = apperr.New(apperr.KindInternal, "category service rejected the request")
+	ErrCategoryLimited  = apperr.New(apperr.KindRateLimited, "category service rate limit exceeded")
+
+	// errCategoryNotFound is a category service 404, the user has no categories.
+	errCategoryNotFound = errors.New("category service returned not found")
 )
 
+// emailUniqueIndex is the case-insensitive unique index on users.email.
+const emailUniqueIndex = "users_email_lower_key"
+
+// createUserOperation scopes the idempotency keys of CreateUser.
+const createUserOperation = "CreateUser"
+
+// TxBeginner starts the transactions of the writes that must be atomic, *database.Pool
+// satisfies it.
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// IdempotencyKey is the Idempotency-Key of a request, scoped to the client sending it.
+type IdempotencyKey struct {
+	Client string
+	Key    string
+}
+
+// CategoryCache caches the category names of users, keyed by user ID.
+type CategoryCache interface {
+	Get(userID int) (CategoryEntry, bool)
+	Set(userID int, entry CategoryEntry, ttl time.Duration)
+	Delete(userID int)
+}
+
+// CategoryEntry is a cached category lookup, NotFound caches a user without categories.
+type CategoryEntry struct {
+	Names    []string
+	NotFound bool
+}
+
+// categoryFetch is an in-flight category fetch, invalidated once the categories it is fetching
+// changed.
+type categoryFetch struct {
+	invalidated bool
+}
+
 type Store struct {
-	db        *db.Queries
+	db        db.Querier
 	catClient *catApi.Client
+	log       *slog.Logger
+
+	catCache       CategoryCache
+	catTTL         time.Duration
+	catNegativeTTL time.Duration
+	catGroup       singleflight.Group
+	// catFetches tracks the in-flight category fetches by user, so a fetch started before an
+	// invalidation doesn't cache the categories it invalidated.
+	catMu      sync.Mutex
+	catFetches map[int]*categoryFetch
+
+	txs            TxBeginner
+	idempotencyTTL time.Duration
+	// txQueries binds the queries to a transaction.
+	txQueries func(tx pgx.Tx) db.Querier
 }
 
-func NewStore(q *db.Queries, c *catApi.Client) *Store {
+func NewStore(q db.Querier, c *catApi.Client, log *slog.Logger) *Store {
 	s := &Store{
 		db:        q,
 		catClient: c,
+		log:       log,
+		txQueries: func(tx pgx.Tx) db.Querier { return db.New(tx) },
 	}
 	return s
 }
 
-func (s *Store) CreateUser(ctx context.Context, name, email string) (int, error) {
-	userId, err := s.db.CreateUser(ctx, db.CreateUserParams{
+// WithCategoryCache puts c in front of the category service. Lookups are cached for ttl, and
+// the users without categories for negativeTTL.
+func (s *Store) WithCategoryCache(c CategoryCache, ttl, negativeTTL time.Duration) *Store {
+	s.catCache = c
+	s.catFetches = make(map[int]*categoryFetch)
+	s.catTTL = ttl
+	s.catNegativeTTL = negativeTTL
+	return s
+}
+
+// WithIdempotency enables the idempotency keys of CreateUser. They are stored in transactions
+// started by txs, and replayed for ttl.
+func (s *Store) WithIdempotency(txs TxBeginner, ttl time.Duration) *Store {
+	s.txs = txs
+	s.idempotencyTTL = ttl
+	return s
+}
+
+// InvalidateUserCategories drops the cached categories of a user, it must be called when they change.
+func (s *Store) InvalidateUserCategories(userID int) {
+	if s.catCache == nil {
+		return
+	}
+	s.catMu.Lock()
+	defer s.catMu.Unlock()
+	if f, ok := s.catFetches[userID]; ok {
+		f.invalidated = true
+	}
+	s.catCache.Delete(userID)
+}
+
+// CreateUser inserts a user. With an idempotency key, a retry of the same request gets the
+// user created by the first one.
+func (s *Store) CreateUser(ctx context.Context, name, email string, key *IdempotencyKey) (*api.User, error) {
+	params := db.CreateUserParams{
+		Name:  name,
+		Email: normalizeEmail(email),
+	}
+	if key != nil {
+		user, err := s.createUserIdempotent(ctx, *key, params)
+		if err != nil {
+			return nil, translateWriteErr(fmt.Errorf("create user with idempotency key: %w", err))
+		}
+		return user, nil
+	}
+
+	user, err := s.db.CreateUser(ctx, params)
+	if err != nil {
+		return nil, translateWriteErr(fmt.Errorf("create user: %w", err))
+	}
+	return toApiUser(user), nil
+}
+
+// createUserIdempotent claims the key and inserts the user in one transaction, so a key is only
+// kept along with the response of a committed insert. A key claimed by a previous request gets
+// its response replayed, a concurrent claim waits for the other transaction to finish.
+func (s *Store) createUserIdempotent(ctx context.Context, key IdempotencyKey, params db.CreateUserParams) (*api.User, error) {
+	if s.txs == nil {
+		return nil, errors.New("idempotency keys are not enabled")
+	}
+	hash, err := requestHash(params)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.txs.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// A no-op once committed.
+	defer func() { _ = tx.Rollback(context.WithoutCancel(ctx)) }()
+	q := s.txQueries(tx)
+
+	claimed, err := q.ClaimIdempotencyKey(ctx, db.ClaimIdempotencyKeyParams{
+		Client:        key.Client,
+		Operation:     createUserOperation,
+		Key:           key.Key,
+		RequestHash:   hash,
+		ExpiredBefore: pgtype.Timestamptz{Time: time.Now().Add(-s.idempotencyTTL), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claimed == 0 {
+		stored, err := q.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+			Client:    key.Client,
+			Operation: createUserOperation,
+			Key:       key.Key,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if stored.RequestHash != hash {
+			return nil, ErrIdempotencyKeyReused
+		}
+		var user api.User
+		if err = user.UnmarshalJSON(stored.Response); err != nil {
+			return nil, fmt.Errorf("decode stored response: %w", err)
+		}
+		return &user, nil
+	}
+
+	row, err := q.CreateUser(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	user := toApiUser(row)
+	response, err := user.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("encode response: %w", err)
+	}
+	err = q.SaveIdempotencyResponse(ctx, db.SaveIdempotencyResponseParams{
+		Client:    key.Client,
+		Operation: createUserOperation,
+		Key:       key.Key,
+		Response:  response,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// requestHash fingerprints the normalized request, so a key reused for another request is
+// detected.
+func requestHash(params db.CreateUserParams) (string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("hash request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// PurgeIdempotencyKeys deletes the keys no longer replayed and returns how many were deleted.
+func (s *Store) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	n, err := s.db.PurgeIdempotencyKeys(ctx, pgtype.Timestamptz{Time: time.Now().Add(-s.idempotencyTTL), Valid: true})
+	if err != nil {
+		return 0, fmt.Errorf("purge idempotency keys: %w", err)
+	}
+	return n, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, userID int, name, email string) (*api.User, error) {
+	user, err := s.db.UpdateUser(ctx, db.UpdateUserParams{
+		ID:    int32(userID),
 		Name:  name,
-		Email: email,
+		Email: normalizeEmail(email),
 	})
 	if err != nil {
-		return 0, err
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, translateWriteErr(fmt.Errorf("update user %d: %w", userID, err))
 	}
-	return int(userId), nil
+	return toApiUser(user), nil
 }
 
-func (s *Store) GetAllUsers(ctx context.Context) (*api.GetAllUsersOKApplicationJSON, error) {
-	users, err := s.db.GetAllUsers(ctx)
+func (s *Store) PatchUser(ctx context.Context, userID int, name, email api.OptString) (*api.User, error) {
+	user, err := s.db.PatchUser(ctx, db.PatchUserParams{
+		ID:    int32(userID),
+		Name:  pgtype.Text{String: name.Value, Valid: name.Set},
+		Email: pgtype.Text{String: normalizeEmail(email.Value), Valid: email.Set},
+	})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrUserNotFound
 		}
+		return nil, translateWriteErr(fmt.Errorf("patch user %d: %w", userID, err))
+	}
+	return toApiUser(user), nil
+}
+
+func toApiUser(user db.User) *api.User {
+	return &api.User{
+		ID:        int(user.ID),
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt.Time,
+		UpdatedAt: user.UpdatedAt.Time,
+	}
+}
+
+// normalizeEmail trims the address and lower-cases it, so it is stored the way the unique index
+// compares it.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// translateWriteErr reports a violation of the email unique index as ErrEmailTaken, other
+// errors are left to apperr.Translate.
+func translateWriteErr(err error) error {
+	if apperr.IsUniqueViolation(err, emailUniqueIndex) {
+		return ErrEmailTaken.Wrap(err)
+	}
+	return err
+}
+
+func (s *Store) DeleteUser(ctx context.Context, userID int) error {
+	affected, err := s.db.DeleteUser(ctx, int32(userID))
+	if err != nil {
+		return fmt.Errorf("delete user %d: %w", userID, err)
+	}
+	if affected == 0 {
+		return ErrUserNotFound
+	}
+	// The categories of the user are deleted along with it.
+	s.InvalidateUserCategories(userID)
+	return nil
+}
+
+func (s *Store) GetAllUsers(ctx context.Context, params api.GetAllUsersParams) (*api.UserPage, error) {
+	arg := db.GetAllUsersParams{
+		Cursor:   pgtype.Int4{Int32: int32(params.Cursor.Value), Valid: params.Cursor.Set},
+		SortDesc: params.Sort.Or(api.GetAllUsersSortAsc) == api.GetAllUsersSortDesc,
+		Name:     pgtype.Text{String: params.Name.Value, Valid: params.Name.Set},
+		Email:    pgtype.Text{String: params.Email.Value, Valid: params.Email.Set},
+	}
+	// Fetch one extra row to find out whether there is a next page.
+	if limit, ok := params.Limit.Get(); ok {
+		arg.PageLimit = pgtype.Int4{Int32: int32(limit) + 1, Valid: true}
+	}
+	users, err := s.db.GetAllUsers(ctx, arg)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+
+	page := api.UserPage{Users: make([]api.User, 0, len(users))}
+	if limit, ok := params.Limit.Get(); ok && len(users) > limit {
+		users = users[:limit]
+		page.NextCursor = api.NewOptInt(int(users[limit-1].ID))
 	}
-	var usersApi api.GetAllUsersOKApplicationJSON
 	for _, user := range users {
-		usersApi = append(usersApi, api.User{
-			ID:    int(user.ID),
-			Name:  user.Name,
-			Email: user.Email,
-		})
+		page.Users = append(page.Users, *toApiUser(user))
 	}
-	return &usersApi, nil
+	return &page, nil
 }
 
-func (s *Store) GetUserCategory(ctx context.Context, userID int) (*api.UserCategory, error) {
+// GetUserCategory returns the user with the names of their categories. When degrade is set, a
+// failing category service doesn't fail the call, the categories are then omitted and the result
+// is flagged as degraded.
+func (s *Store) GetUserCategory(ctx context.Context, userID int, degrade bool) (*api.UserCategory, error) {
 	user, err := s.db.GetUserByID(ctx, int32(userID))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrUserNotFound
 		}
+		return nil, fmt.Errorf("get user %d: %w", userID, err)
+	}
+	categories, err := s.userCategories(ctx, userID)
+	if err != nil {
+		if !degrade || apperr.KindOf(err) != apperr.KindUnavailable {
+			return nil, fmt.Errorf("get categories of user %d: %w", userID, err)
+		}
+		return &api.UserCategory{
+			ID:       int(user.ID),
+			Name:     user.Name,
+			Degraded: api.NewOptBool(true),
+		}, nil
+	}
+	return &api.UserCategory{
+		ID:         int(user.ID),
+		Name:       user.Name,
+		Categories: categories,
+	}, nil
+}
+
+func (s *Store) userCategories(ctx context.Context, userID int) ([]string, error) {
+	if s.catCache == nil {
+		names, err := s.fetchUserCategories(ctx, userID)
+		if errors.Is(err, errCategoryNotFound) {
+			return []string{}, nil
+		}
+		return names, err
+	}
+	if e, ok := s.catCache.Get(userID); ok {
+		if e.NotFound {
+			return []string{}, nil
+		}
+		return e.Names, nil
 	}
-	catRes, err := s.catClient.GetCategoryById(ctx, catApi.GetCategoryByIdParams{ID: userID})
+
+	// Concurrent misses for the same user share a single call, which must not be canceled by
+	// the caller that happened to start it.
+	res, err, _ := s.catGroup.Do(strconv.Itoa(userID), func() (interface{}, error) {
+		fetch := &categoryFetch{}
+		s.catMu.Lock()
+		s.catFetches[userID] = fetch
+		s.catMu.Unlock()
+
+		names, err := s.fetchUserCategories(context.WithoutCancel(ctx), userID)
+		if errors.Is(err, errCategoryNotFound) {
+			names, err = []string{}, nil
+		}
+
+		s.catMu.Lock()
+		defer s.catMu.Unlock()
+		delete(s.catFetches, userID)
+		switch {
+		case err != nil, fetch.invalidated:
+		case len(names) > 0:
+			s.catCache.Set(userID, CategoryEntry{Names: names}, s.catTTL)
+		default:
+			s.catCache.Set(userID, CategoryEntry{NotFound: true}, s.catNegativeTTL)
+		}
+		return names, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.([]string), nil
+}
+
+func (s *Store) fetchUserCategories(ctx context.Context, userID int) ([]string, error) {
+	catRes, err := s.catClient.GetUserCategories(ctx, catApi.GetUserCategoriesParams{UserId: userID})
 
 	if err != nil {
 		var apiErr *catApi.ErrorStatusCode
 		if errors.As(err, &apiErr) {
-			return nil, ErrNoCategoryFound
+			if apiErr.StatusCode == http.StatusNotFound {
+				return nil, errCategoryNotFound
+			}
+			s.log.WarnContext(ctx, "Category service returned an error", "status", apiErr.StatusCode, "error", apiErr.Response.Message)
+			switch {
+			case apiErr.StatusCode == http.StatusTooManyRequests:
+				return nil, ErrCategoryLimited.Wrap(err)
+			case apiErr.StatusCode < http.StatusInternalServerError:
+				return nil, ErrCategoryRejected.Wrap(err)
+			}
+			return nil, ErrNoCategoryFound.Wrap(err)
 		}
-		return nil, ErrCategoryConn
+		s.log.WarnContext(ctx, "Category service call failed", "error", err)
+		return nil, ErrCategoryConn.Wrap(err)
 	}
 
 	switch res := catRes.(type) {
-	case *catApi.Category:
-		return &api.UserCategory{
-			ID:       int(user.ID),
-			Name:     user.Name,
-			Category: res.GetName(),
-		}, nil
+	case *catApi.GetUserCategoriesOKApplicationJSON:
+		categories := make([]string, 0, len(*res))
+		for _, c := range *res {
+			categories = append(categories, c.GetName())
+		}
+		return categories, nil
 	default:
-		return nil, fmt.Errorf("unexpected response from category service")
+		return nil, fmt.Errorf("unexpected response %T from category service", res)
 	}
 }
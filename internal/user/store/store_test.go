@@ -0,0 +1,422 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	db "github.com/opplieam/dist-mono/db/sqlc"
+	"github.com/opplieam/dist-mono/internal/apperr"
+	catApi "github.com/opplieam/dist-mono/internal/category/api"
+	"github.com/opplieam/dist-mono/internal/user/api"
+)
+
+// failingDB is a db.DBTX where every call fails with err.
+type failingDB struct {
+	err error
+}
+
+func (f failingDB) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, f.err
+}
+
+func (f failingDB) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return nil, f.err
+}
+
+func (f failingDB) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return failingRow{err: f.err}
+}
+
+func (f failingDB) Begin(context.Context) (pgx.Tx, error) {
+	return failingTx{db: f}, nil
+}
+
+// failingTx is a transaction of a failingDB, only its commit fails.
+type failingTx struct {
+	pgx.Tx
+	db failingDB
+}
+
+func (t failingTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return t.db.Exec(ctx, sql, args...)
+}
+
+func (t failingTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return t.db.Query(ctx, sql, args...)
+}
+
+func (t failingTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return t.db.QueryRow(ctx, sql, args...)
+}
+
+func (t failingTx) Commit(context.Context) error {
+	return t.db.err
+}
+
+func (t failingTx) Rollback(context.Context) error {
+	return nil
+}
+
+type failingRow struct {
+	err error
+}
+
+func (r failingRow) Scan(...any) error {
+	return r.err
+}
+
+type errCase struct {
+	name     string
+	dbErr    error
+	wantKind apperr.Kind
+	wantIs   error
+}
+
+func TestStoreErrors(t *testing.T) {
+	errConn := errors.New("connection reset by peer")
+	errSerialization := &pgconn.PgError{Code: "40001"}
+	errEmailTaken := &pgconn.PgError{Code: "23505", ConstraintName: emailUniqueIndex}
+
+	calls := []struct {
+		name     string
+		notFound bool // whether pgx.ErrNoRows means the user doesn't exist
+		write    bool // whether the call writes the email
+		call     func(s *Store) error
+	}{
+		{"CreateUser", false, true, func(s *Store) error {
+			_, err := s.CreateUser(context.Background(), "john", "john@example.com", nil)
+			return err
+		}},
+		{"CreateUser with idempotency key", false, true, func(s *Store) error {
+			_, err := s.CreateUser(context.Background(), "john", "john@example.com", &IdempotencyKey{Client: "1", Key: "k"})
+			return err
+		}},
+		{"UpdateUser", true, true, func(s *Store) error {
+			_, err := s.UpdateUser(context.Background(), 1, "john", "john@example.com")
+			return err
+		}},
+		{"PatchUser", true, true, func(s *Store) error {
+			_, err := s.PatchUser(context.Background(), 1, api.OptString{}, api.NewOptString("john@example.com"))
+			return err
+		}},
+		{"DeleteUser", false, false, func(s *Store) error {
+			return s.DeleteUser(context.Background(), 1)
+		}},
+		{"GetAllUsers", false, false, func(s *Store) error {
+			_, err := s.GetAllUsers(context.Background(), api.GetAllUsersParams{Limit: api.NewOptInt(10)})
+			return err
+		}},
+		// A database failure must not be degraded like a category service failure.
+		{"GetUserCategory", true, false, func(s *Store) error {
+			_, err := s.GetUserCategory(context.Background(), 1, true)
+			return err
+		}},
+	}
+
+	for _, c := range calls {
+		tests := []errCase{
+			{"connection error", errConn, apperr.KindInternal, errConn},
+			{"serialization failure", errSerialization, apperr.KindConflict, errSerialization},
+		}
+		if c.notFound {
+			tests = append(tests, errCase{"no rows", pgx.ErrNoRows, apperr.KindNotFound, ErrUserNotFound})
+		}
+		if c.write {
+			tests = append(tests, errCase{"email taken", errEmailTaken, apperr.KindConflict, ErrEmailTaken})
+		}
+
+		for _, tt := range tests {
+			t.Run(c.name+"/"+tt.name, func(t *testing.T) {
+				fdb := failingDB{err: tt.dbErr}
+				s := NewStore(db.New(fdb), nil, slog.New(slog.NewTextHandler(io.Discard, nil))).WithIdempotency(fdb, time.Hour)
+				err := c.call(s)
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				if !errors.Is(err, tt.wantIs) {
+					t.Errorf("error %q doesn't wrap %q", err, tt.wantIs)
+				}
+				if got := apperr.Translate(err).Kind; got != tt.wantKind {
+					t.Errorf("kind = %v, want %v", got, tt.wantKind)
+				}
+			})
+		}
+	}
+}
+
+// memQueries is a db.Querier keeping the users and idempotency keys in memory. Its
+// transactions only count the commits, they share the state of the queries.
+type memQueries struct {
+	db.Querier
+	users   map[int32]db.User
+	keys    map[db.GetIdempotencyKeyParams]db.IdempotencyKey
+	commits int
+}
+
+func newMemQueries() *memQueries {
+	return &memQueries{
+		users: make(map[int32]db.User),
+		keys:  make(map[db.GetIdempotencyKeyParams]db.IdempotencyKey),
+	}
+}
+
+// newMemStore returns a store using q for its queries and transactions.
+func newMemStore(q *memQueries) *Store {
+	s := NewStore(q, nil, slog.New(slog.NewTextHandler(io.Discard, nil))).WithIdempotency(q, time.Hour)
+	s.txQueries = func(pgx.Tx) db.Querier { return q }
+	return s
+}
+
+func (q *memQueries) Begin(context.Context) (pgx.Tx, error) {
+	return memTx{q: q}, nil
+}
+
+func (q *memQueries) CreateUser(_ context.Context, arg db.CreateUserParams) (db.User, error) {
+	user := db.User{ID: int32(len(q.users) + 1), Name: arg.Name, Email: arg.Email}
+	q.users[user.ID] = user
+	return user, nil
+}
+
+func (q *memQueries) GetUserByID(_ context.Context, id int32) (db.User, error) {
+	user, ok := q.users[id]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+func (q *memQueries) ClaimIdempotencyKey(_ context.Context, arg db.ClaimIdempotencyKeyParams) (int64, error) {
+	k := db.GetIdempotencyKeyParams{Client: arg.Client, Operation: arg.Operation, Key: arg.Key}
+	if _, ok := q.keys[k]; ok {
+		return 0, nil
+	}
+	q.keys[k] = db.IdempotencyKey{Client: arg.Client, Operation: arg.Operation, Key: arg.Key, RequestHash: arg.RequestHash}
+	return 1, nil
+}
+
+func (q *memQueries) GetIdempotencyKey(_ context.Context, arg db.GetIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	key, ok := q.keys[arg]
+	if !ok {
+		return db.IdempotencyKey{}, pgx.ErrNoRows
+	}
+	return key, nil
+}
+
+func (q *memQueries) SaveIdempotencyResponse(_ context.Context, arg db.SaveIdempotencyResponseParams) error {
+	k := db.GetIdempotencyKeyParams{Client: arg.Client, Operation: arg.Operation, Key: arg.Key}
+	key := q.keys[k]
+	key.Response = arg.Response
+	q.keys[k] = key
+	return nil
+}
+
+type memTx struct {
+	pgx.Tx
+	q *memQueries
+}
+
+func (t memTx) Commit(context.Context) error {
+	t.q.commits++
+	return nil
+}
+
+func (t memTx) Rollback(context.Context) error {
+	return nil
+}
+
+func TestCreateUserIdempotency(t *testing.T) {
+	q := newMemQueries()
+	s := newMemStore(q)
+	key := &IdempotencyKey{Client: "1", Key: "retry-me"}
+
+	user, err := s.CreateUser(context.Background(), "john", "John@Example.com", key)
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	if q.commits != 1 || len(q.users) != 1 {
+		t.Fatalf("first request: %d commits and %d users, want 1 and 1", q.commits, len(q.users))
+	}
+
+	// The retry is the same request once normalized.
+	replayed, err := s.CreateUser(context.Background(), "john", "john@example.com ", key)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if *replayed != *user || q.commits != 1 || len(q.users) != 1 {
+		t.Errorf("retry = %+v with %d users, want the replayed %+v without a new insert", replayed, len(q.users), user)
+	}
+
+	_, err = s.CreateUser(context.Background(), "john", "jane@example.com", key)
+	if !errors.Is(err, ErrIdempotencyKeyReused) || apperr.Translate(err).Kind != apperr.KindUnprocessable {
+		t.Fatalf("key reused with another body: error = %v, want ErrIdempotencyKeyReused", err)
+	}
+
+	other, err := s.CreateUser(context.Background(), "john", "jane@example.com", &IdempotencyKey{Client: "2", Key: "retry-me"})
+	if err != nil || other.ID == user.ID {
+		t.Fatalf("same key of another client = %+v, %v, want a new user", other, err)
+	}
+}
+
+type staticToken struct{}
+
+func (staticToken) BearerAuth(context.Context, catApi.OperationName) (catApi.BearerAuth, error) {
+	return catApi.BearerAuth{Token: "token"}, nil
+}
+
+// newCategoryClient returns a client of a category service answering every call with status
+// and body, counting them in calls.
+func newCategoryClient(t *testing.T, status int, body string, calls *int) *catApi.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := catApi.NewClient(srv.URL, staticToken{})
+	if err != nil {
+		t.Fatalf("new category client: %v", err)
+	}
+	return client
+}
+
+// newCategoryStore returns a store with user 1, whose categories come from client.
+func newCategoryStore(client *catApi.Client) *Store {
+	q := newMemQueries()
+	q.users[1] = db.User{ID: 1, Name: "john"}
+	return NewStore(q, client, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestGetUserCategoryStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantDegraded bool
+		wantKind     apperr.Kind
+	}{
+		{name: "server error degrades", status: http.StatusServiceUnavailable, wantDegraded: true},
+		{name: "bad request", status: http.StatusBadRequest, wantKind: apperr.KindInternal},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantKind: apperr.KindInternal},
+		{name: "forbidden", status: http.StatusForbidden, wantKind: apperr.KindInternal},
+		{name: "rate limited", status: http.StatusTooManyRequests, wantKind: apperr.KindRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			s := newCategoryStore(newCategoryClient(t, tt.status, `{"message":"nope"}`, &calls))
+
+			res, err := s.GetUserCategory(context.Background(), 1, true)
+			if tt.wantDegraded {
+				if err != nil || !res.Degraded.Or(false) {
+					t.Fatalf("GetUserCategory = %+v, %v, want a degraded result", res, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("GetUserCategory = %+v, want an error", res)
+			}
+			if got := apperr.Translate(err).Kind; got != tt.wantKind {
+				t.Errorf("error kind = %v, want %v", got, tt.wantKind)
+			}
+		})
+	}
+}
+
+// mapCache is a CategoryCache without expiry, it records the TTL of every entry.
+type mapCache struct {
+	entries map[int]CategoryEntry
+	ttls    map[int]time.Duration
+}
+
+func (c *mapCache) Get(userID int) (CategoryEntry, bool) {
+	e, ok := c.entries[userID]
+	return e, ok
+}
+
+func (c *mapCache) Set(userID int, entry CategoryEntry, ttl time.Duration) {
+	c.entries[userID] = entry
+	c.ttls[userID] = ttl
+}
+
+func (c *mapCache) Delete(userID int) {
+	delete(c.entries, userID)
+}
+
+func TestUserCategoriesNegativeCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "not found", status: http.StatusNotFound, body: `{"message":"user not found"}`},
+		{name: "no categories", status: http.StatusOK, body: `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			s := newCategoryStore(newCategoryClient(t, tt.status, tt.body, &calls))
+			c := &mapCache{entries: make(map[int]CategoryEntry), ttls: make(map[int]time.Duration)}
+			s.WithCategoryCache(c, time.Minute, time.Second)
+
+			for i := 0; i < 2; i++ {
+				res, err := s.GetUserCategory(context.Background(), 1, false)
+				if err != nil {
+					t.Fatalf("lookup %d: %v", i, err)
+				}
+				if len(res.Categories) != 0 || res.Degraded.Or(false) {
+					t.Fatalf("lookup %d = %+v, want no categories", i, res)
+				}
+			}
+			if calls != 1 {
+				t.Errorf("category service called %d times, want 1", calls)
+			}
+			if e := c.entries[1]; !e.NotFound || c.ttls[1] != time.Second {
+				t.Errorf("cached %+v for %v, want a negative entry for the negative ttl", e, c.ttls[1])
+			}
+		})
+	}
+}
+
+func TestInvalidateDuringCategoryFetch(t *testing.T) {
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(fetching)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `[{"id":1,"name":"books","user_id":1}]`)
+	}))
+	t.Cleanup(srv.Close)
+	client, err := catApi.NewClient(srv.URL, staticToken{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newCategoryStore(client)
+	c := &mapCache{entries: make(map[int]CategoryEntry), ttls: make(map[int]time.Duration)}
+	s.WithCategoryCache(c, time.Minute, time.Second)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.GetUserCategory(context.Background(), 1, false)
+		done <- err
+	}()
+	<-fetching
+	s.InvalidateUserCategories(1)
+	close(release)
+	if err = <-done; err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if e, ok := c.entries[1]; ok {
+		t.Errorf("fetch started before the invalidation cached %+v", e)
+	}
+}